@@ -0,0 +1,168 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pprofProfiles 通过pprof.Handler输出的profile
+var pprofProfiles = []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"}
+
+// RouteInfo 已注册的路由
+type RouteInfo struct {
+	Method   string   `json:"method"`
+	Path     string   `json:"path"`
+	Handler  string   `json:"handler"`  // 路由处理函数名称
+	Handlers []string `json:"handlers"` // 全部中间件和处理函数名称
+}
+
+// Admin 在prefix下挂载运维路由，handlers为保护这些路由的中间件（如鉴权），至少需要一个，否则panic：
+//
+//	{prefix}/pprof/...    pprof profile
+//	{prefix}/expvar       expvar
+//	{prefix}/goroutines   全部goroutine栈
+//	{prefix}/gc           GC和内存统计
+//	{prefix}/routes       已注册的路由
+//	{prefix}/config       当前配置
+//
+// 非debug环境下默认不挂载，需Configure.SetAdmin(true)显式开启
+func (s *Server) Admin(prefix string, handlers ...HandlerFunc) {
+	if len(handlers) == 0 {
+		panic("nets: Admin requires at least one guard handler")
+	}
+	if !s.Config.admin {
+		debugPrintf("[WARNING] admin routes are disabled in %s env, use Configure.SetAdmin(true) to enable\n", s.Config.env)
+		return
+	}
+
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix != "/" {
+		prefix += "/"
+	}
+	s.Group(prefix, handlers...)
+
+	s.GET(prefix+"pprof/", adminPprofIndex)
+	s.GET(prefix+"pprof/cmdline", WrapF(pprof.Cmdline))
+	s.GET(prefix+"pprof/profile", WrapF(pprof.Profile))
+	s.GET(prefix+"pprof/symbol", WrapF(pprof.Symbol))
+	s.POST(prefix+"pprof/symbol", WrapF(pprof.Symbol))
+	s.GET(prefix+"pprof/trace", WrapF(pprof.Trace))
+	for _, name := range pprofProfiles {
+		s.GET(prefix+"pprof/"+name, WrapH(pprof.Handler(name)))
+	}
+
+	s.GET(prefix+"expvar", WrapH(expvar.Handler()))
+	s.GET(prefix+"goroutines", adminGoroutines)
+	s.GET(prefix+"gc", adminGC)
+	s.GET(prefix+"routes", func(c *Context) {
+		c.JSON(http.StatusOK, Result{Data: c.server.Routes()})
+	})
+	s.GET(prefix+"config", func(c *Context) {
+		c.JSON(http.StatusOK, Result{Data: c.server.Config.values()})
+	})
+}
+
+// Routes 返回已注册的路由，需在Run之后调用
+func (s *Server) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0)
+	for _, tree := range s.trees {
+		routes = appendRoutes(routes, tree.method, tree.root, nil)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// appendRoutes 深度优先遍历前缀树，收集有私有处理函数的结点
+func appendRoutes(routes []RouteInfo, method string, root *node, names []string) []RouteInfo {
+	names = append(names[:len(names):len(names)], root.middlewareNames...)
+	if len(root.handlers) > 0 {
+		handlers := append(names[:len(names):len(names)], root.handlerNames...)
+		routes = append(routes, RouteInfo{
+			Method:   method,
+			Path:     root.path,
+			Handler:  IndexOfStrings(handlers, len(handlers)-1, ""),
+			Handlers: handlers,
+		})
+	}
+	for _, child := range root.children {
+		routes = appendRoutes(routes, method, child, names)
+	}
+	return routes
+}
+
+// adminPprofIndex 输出pprof首页，pprof.Index只识别/debug/pprof/前缀
+func adminPprofIndex(c *Context) {
+	r := c.Request.Clone(c.Request.Context())
+	r.URL.Path = "/debug/pprof/"
+	pprof.Index(&c.responser, r)
+}
+
+// adminGoroutines 输出全部goroutine栈
+func adminGoroutines(c *Context) {
+	buf := make([]byte, watchdogStackBufSize)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+
+	if !c.writable() {
+		return
+	}
+	c.responser.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.responser.WriteHeader(http.StatusOK)
+	c.responser.Write(buf)
+}
+
+// adminGC 输出GC和内存统计
+func adminGC(c *Context) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	gcStats := debug.GCStats{PauseQuantiles: make([]time.Duration, 5)}
+	debug.ReadGCStats(&gcStats)
+
+	c.JSON(http.StatusOK, Result{Data: AnyMap{
+		"goroutines":     runtime.NumGoroutine(),
+		"numGC":          gcStats.NumGC,
+		"lastGC":         gcStats.LastGC,
+		"pauseTotal":     gcStats.PauseTotal.String(),
+		"pauseQuantiles": durationStrings(gcStats.PauseQuantiles),
+		"heapAlloc":      memStats.HeapAlloc,
+		"heapInuse":      memStats.HeapInuse,
+		"heapObjects":    memStats.HeapObjects,
+		"stackInuse":     memStats.StackInuse,
+		"sys":            memStats.Sys,
+		"totalAlloc":     memStats.TotalAlloc,
+		"mallocs":        memStats.Mallocs,
+		"frees":          memStats.Frees,
+		"nextGC":         memStats.NextGC,
+		"gcCPUFraction":  memStats.GCCPUFraction,
+	}})
+}
+
+// durationStrings 将Duration列表转换为字符串列表
+func durationStrings(durations []time.Duration) []string {
+	strs := make([]string, len(durations))
+	for i, d := range durations {
+		strs[i] = d.String()
+	}
+	return strs
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminGuard 以Authorization头鉴权，缺失返回401，不匹配返回403
+func adminGuard(c *Context) {
+	switch c.Request.Header.Get("Authorization") {
+	case "":
+		c.AbortStatus(http.StatusUnauthorized)
+	case "Bearer admin":
+		c.Next()
+	default:
+		c.AbortStatus(http.StatusForbidden)
+	}
+}
+
+func TestAdminEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(config *Configure)
+		admin bool
+	}{
+		{"debug default", func(config *Configure) { config.SetEnv(EnvDevelopment) }, true},
+		{"release default", func(config *Configure) { config.SetEnv(EnvRelease) }, false},
+		{"enabled in release", func(config *Configure) { config.SetAdmin(true); config.SetEnv(EnvProduction) }, true},
+		{"disabled in debug", func(config *Configure) { config.SetAdmin(false); config.SetEnv(EnvTest) }, false},
+		{"release after enabled", func(config *Configure) { config.SetEnv(EnvRelease); config.SetAdmin(true) }, true},
+	}
+	for _, tt := range tests {
+		s := New()
+		tt.setup(s.Config)
+		s.Admin("/debug", adminGuard)
+		s.buildTrees()
+
+		req := httptest.NewRequest(http.MethodGet, "/debug/gc", nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		want := http.StatusNotFound
+		if tt.admin {
+			want = http.StatusOK
+		}
+		if w := do(s, req); w.Code != want {
+			t.Errorf("%s: GET /debug/gc = %d, want %d", tt.name, w.Code, want)
+		}
+	}
+}
+
+func TestAdminRequiresGuard(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "guard") {
+			t.Errorf("Admin without guard recover() = %v, want guard panic", r)
+		}
+	}()
+	s := New()
+	s.Admin("/debug")
+}
+
+func TestAdminGuard(t *testing.T) {
+	s := New()
+	s.Config.SetAdmin(true)
+	s.GET("/debugx", func(c *Context) {})
+	s.Admin("/debug/", adminGuard)
+	s.buildTrees()
+
+	paths := []string{
+		"/debug/pprof/",
+		"/debug/pprof/cmdline",
+		"/debug/pprof/heap",
+		"/debug/pprof/goroutine",
+		"/debug/expvar",
+		"/debug/goroutines",
+		"/debug/gc",
+		"/debug/routes",
+		"/debug/config",
+	}
+	tests := []struct {
+		authorization string
+		status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer guest", http.StatusForbidden},
+		{"Bearer admin", http.StatusOK},
+	}
+	for _, path := range paths {
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := do(s, req)
+			if w.Code != tt.status {
+				t.Errorf("GET %s with %q = %d, want %d", path, tt.authorization, w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK && w.Body.Len() > 0 {
+				t.Errorf("GET %s with %q leaked body %.40q", path, tt.authorization, w.Body.String())
+			}
+		}
+	}
+
+	// 前缀相同但不在分组内的路由不受guard影响
+	if w := do(s, httptest.NewRequest(http.MethodGet, "/debugx", nil)); w.Code != http.StatusOK {
+		t.Errorf("GET /debugx = %d, want 200", w.Code)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	s := New()
+	s.Config.SetAdmin(true)
+	s.GET("/users/:id", routeListUsers)
+	s.Admin("/admin", adminGuard)
+	s.buildTrees()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	w := do(s, req)
+	var result struct {
+		Data []RouteInfo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("GET /admin/routes = %d %s: %v", w.Code, w.Body.String(), err)
+	}
+	var found bool
+	for _, route := range result.Data {
+		if route.Path == "/users/:id" {
+			found = route.Method == http.MethodGet && route.Handler == "nets.routeListUsers"
+		}
+		if strings.HasPrefix(route.Path, "/admin/") && IndexOfStrings(route.Handlers, len(route.Handlers)-2, "") != "nets.adminGuard" {
+			t.Errorf("admin route %s handlers = %v, want guarded by nets.adminGuard", route.Path, route.Handlers)
+		}
+	}
+	if !found {
+		t.Errorf("GET /admin/routes = %+v, want GET /users/:id", result.Data)
+	}
+}
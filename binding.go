@@ -0,0 +1,220 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrEmptyBody 请求体为空
+	ErrEmptyBody = errors.New("nets: request body is empty")
+	// ErrInvalidBindTarget 绑定目标不是结构体指针
+	ErrInvalidBindTarget = errors.New("nets: bind target must be a non-nil pointer to struct")
+)
+
+// bindSources 按优先级排列的字段tag及其取值函数
+var bindSources = []struct {
+	tag    string
+	values func(c *Context, key string) ([]string, bool)
+}{
+	{"path", func(c *Context, key string) ([]string, bool) {
+		if value, ok := c.Param(key); ok {
+			return []string{value}, true
+		}
+		return nil, false
+	}},
+	{"query", (*Context).QueryArray},
+	{"form", (*Context).FormArray},
+	{"header", func(c *Context, key string) ([]string, bool) {
+		values := c.Request.Header.Values(key)
+		return values, len(values) > 0
+	}},
+}
+
+// Validator 实现该接口的绑定目标在绑定完成后会调用Validate校验
+type Validator interface {
+	Validate() error
+}
+
+// BindJSON 使用Server的JSONCodec将json请求体解析到obj
+func (c *Context) BindJSON(obj interface{}) error {
+	data, err := c.Body()
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return ErrEmptyBody
+	}
+
+	return c.server.json.Unmarshal(data, obj)
+}
+
+// Bind 将请求数据绑定到结构体指针obj
+// json请求体使用JSONCodec解析，随后按字段tag依次从路由参数(path)、查询参数(query)、表单(form)、请求头(header)取值，
+// binding:"required"的字段缺失时返回错误，obj实现Validator接口时调用Validate校验
+func (c *Context) Bind(obj interface{}) error {
+	c.guard()
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return ErrInvalidBindTarget
+	}
+
+	if isJSONContentType(c.Request.Header.Get("Content-Type")) {
+		if err := c.BindJSON(obj); err != nil && err != ErrEmptyBody {
+			return err
+		}
+	}
+
+	if err := c.bindStruct(v.Elem()); err != nil {
+		return err
+	}
+
+	if validator, ok := obj.(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+// bindStruct 按字段tag绑定结构体字段
+func (c *Context) bindStruct(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		// 嵌入的结构体即使类型未导出，其导出字段仍可绑定
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := c.bindStruct(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		found := false
+		for _, source := range bindSources {
+			key := field.Tag.Get(source.tag)
+			if key == "" || key == "-" {
+				continue
+			}
+			if values, ok := source.values(c, key); ok {
+				if err := setFieldValues(fv, values); err != nil {
+					return fmt.Errorf("nets: bind %s %q: %v", source.tag, key, err)
+				}
+				found = true
+				break
+			}
+		}
+
+		if !found && hasTagOption(field.Tag.Get("binding"), "required") && fv.IsZero() {
+			return fmt.Errorf("nets: field %s is required", field.Name)
+		}
+	}
+	return nil
+}
+
+// hasTagOption 逗号分隔的tag中是否包含option，如binding:"required,min=1"
+func hasTagOption(tag, option string) bool {
+	for _, opt := range strings.Split(tag, ",") {
+		if strings.TrimSpace(opt) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// setFieldValues 将字符串值转换后赋给字段，切片字段使用全部值，其他字段使用第一个值
+func setFieldValues(fv reflect.Value, values []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setFieldValue(fv, values[0])
+}
+
+// setFieldValue 将字符串值转换后赋给字段
+func setFieldValue(fv reflect.Value, value string) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setFieldValue(fv.Elem(), value)
+	}
+
+	if fv.CanAddr() {
+		if unmarshaler, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(value))
+		}
+	}
+
+	switch fv.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		// []byte
+		fv.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// isJSONContentType Content-Type是否为json
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == MIMEJSON || strings.HasSuffix(mediaType, "+json")
+}
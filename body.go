@@ -0,0 +1,74 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ErrBodyTooLarge 请求体超出缓存上限
+var ErrBodyTooLarge = errors.New("nets: request body too large")
+
+// Body 读取并缓存请求体，之后可多次调用；每次调用都会将Request.Body重置为从头读取的缓存，
+// 使后续的绑定、表单解析和trace读取到相同的数据。请求体超出bodyCacheMax时响应413并返回ErrBodyTooLarge
+func (c *Context) Body() ([]byte, error) {
+	c.guard()
+	if c.bodyCache == nil {
+		data, err := c.readBody()
+		if err != nil {
+			return nil, err
+		}
+		c.bodyCache = data
+	}
+	c.rewindBody()
+	return c.bodyCache, nil
+}
+
+// readBody 读取请求体，超出bodyCacheMax时响应413，bodyCacheMax小于等于0时不限制
+func (c *Context) readBody() ([]byte, error) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return []byte{}, nil
+	}
+
+	limit := c.server.Config.bodyCacheMax
+	if limit > 0 && c.Request.ContentLength > limit {
+		return nil, c.abortBodyTooLarge()
+	}
+
+	var reader io.Reader = c.Request.Body
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit+1)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, c.abortBodyTooLarge()
+		}
+		return nil, err
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, c.abortBodyTooLarge()
+	}
+	return data, nil
+}
+
+// abortBodyTooLarge 响应413并返回ErrBodyTooLarge
+func (c *Context) abortBodyTooLarge() error {
+	c.Error(ErrBodyTooLarge).SetType(ErrorTypePublic).SetStatus(http.StatusRequestEntityTooLarge)
+	c.AbortStatus(http.StatusRequestEntityTooLarge)
+	return ErrBodyTooLarge
+}
+
+// rewindBody 已缓存请求体时，将Request.Body重置为从头读取的缓存
+func (c *Context) rewindBody() {
+	if c.bodyCache != nil {
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(c.bodyCache))
+	}
+}
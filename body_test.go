@@ -0,0 +1,79 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bodyUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+// postJSON 以json请求体发起POST请求，返回http status
+func postJSON(s *Server, path, body string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", MIMEJSON)
+	s.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestBindJSONDefaultConfig(t *testing.T) {
+	s := New()
+	var user bodyUser
+	var bindErr error
+	s.POST("/users", func(c *Context) {
+		bindErr = c.BindJSON(&user)
+	})
+	s.buildTrees()
+
+	status := postJSON(s, "/users", `{"name":"nets","age":3}`)
+	if status != http.StatusOK || bindErr != nil {
+		t.Fatalf("POST /users = %d, err %v, want %d", status, bindErr, http.StatusOK)
+	}
+	if user.Name != "nets" || user.Age != 3 {
+		t.Errorf("BindJSON = %+v, want {Name:nets Age:3}", user)
+	}
+}
+
+func TestBodyCacheMax(t *testing.T) {
+	small := `{"name":"nets","age":3}`
+	large := `{"name":"` + strings.Repeat("n", int(defaultBodyCacheMax)) + `"}`
+	tests := []struct {
+		max    int64 // set为false时使用默认配置
+		set    bool
+		body   string
+		status int
+		err    error
+	}{
+		{0, false, small, http.StatusOK, nil},
+		{0, false, large, http.StatusRequestEntityTooLarge, ErrBodyTooLarge},
+		{8, true, small, http.StatusRequestEntityTooLarge, ErrBodyTooLarge},
+		{0, true, large, http.StatusOK, nil},
+		{-1, true, large, http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		s := New()
+		if tt.set {
+			s.Config.SetBodyCacheMax(tt.max)
+		}
+		var bodyErr error
+		s.POST("/users", func(c *Context) {
+			_, bodyErr = c.Body()
+		})
+		s.buildTrees()
+
+		status := postJSON(s, "/users", tt.body)
+		if status != tt.status || bodyErr != tt.err {
+			t.Errorf("bodyCacheMax %d (set %v), body %d bytes: POST /users = %d, err %v, want %d, err %v",
+				tt.max, tt.set, len(tt.body), status, bodyErr, tt.status, tt.err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errJSONTrailingData json顶层值之后还有多余数据
+var errJSONTrailingData = errors.New("nets: invalid data after top-level json value")
+
+// JSONCodec json编解码器
+// Context.JSON、JSON绑定和trace序列化均使用Server上设置的JSONCodec
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdJSONCodec 基于encoding/json的JSONCodec
+type StdJSONCodec struct {
+	// 是否转义HTML字符（<、>、&）
+	EscapeHTML bool
+	// 解码时是否禁止未知字段
+	DisallowUnknownFields bool
+	// 解码时是否将数字解析为json.Number
+	UseNumber bool
+	// 编码时是否按字典序输出对象的key（包括结构体字段），便于比对和生成稳定的签名
+	SortKeys bool
+}
+
+// newJSONCodec return the default json codec
+func newJSONCodec() JSONCodec {
+	return &StdJSONCodec{EscapeHTML: true}
+}
+
+// Marshal 实现JSONCodec接口
+func (codec *StdJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	if !codec.SortKeys {
+		return codec.encode(v)
+	}
+
+	// encoding/json按字典序输出map的key，先转换为通用结构再编码即可对结构体字段排序
+	data, err := codec.encode(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return codec.encode(generic)
+}
+
+// encode 按EscapeHTML设置编码v
+func (codec *StdJSONCodec) encode(v interface{}) ([]byte, error) {
+	if codec.EscapeHTML {
+		return json.Marshal(v)
+	}
+
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	// json.Encoder.Encode会追加换行符
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// Unmarshal 实现JSONCodec接口
+func (codec *StdJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	if !codec.DisallowUnknownFields && !codec.UseNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if codec.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if codec.UseNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	// 与json.Unmarshal一致，顶层值之后只允许空白
+	if _, err := decoder.Token(); err != io.EOF {
+		return errJSONTrailingData
+	}
+	return nil
+}
+
+// SetJSONCodec 设置json编解码器
+func (s *Server) SetJSONCodec(codec JSONCodec) {
+	if codec == nil {
+		panic("nets: json codec is nil")
+	}
+	s.json = codec
+}
+
+// JSONCodec 返回当前使用的json编解码器
+func (s *Server) JSONCodec() JSONCodec {
+	return s.json
+}
+
+// marshalJSON 使用Server的JSONCodec序列化v，debug环境下按配置格式化输出
+func (s *Server) marshalJSON(v interface{}) ([]byte, error) {
+	data, err := s.json.Marshal(v)
+	if err != nil || !s.Config.prettyJSON || !s.Config.debug {
+		return data, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = json.Indent(buf, data, "", "    "); err != nil {
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
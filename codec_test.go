@@ -0,0 +1,97 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type codecUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+	HTML string `json:"html,omitempty"`
+}
+
+func TestStdJSONCodecMarshal(t *testing.T) {
+	tests := []struct {
+		codec StdJSONCodec
+		v     interface{}
+		want  string
+	}{
+		{StdJSONCodec{EscapeHTML: true}, codecUser{Name: "n", HTML: "<a>"}, `{"name":"n","age":0,"html":"\u003ca\u003e"}`},
+		{StdJSONCodec{}, codecUser{Name: "n", HTML: "<a>"}, `{"name":"n","age":0,"html":"<a>"}`},
+		{StdJSONCodec{SortKeys: true}, codecUser{Name: "n", Age: 1}, `{"age":1,"name":"n"}`},
+		{StdJSONCodec{SortKeys: true}, AnyMap{"b": []codecUser{{Name: "n"}}, "a": uint64(12345678901234567890)}, `{"a":12345678901234567890,"b":[{"age":0,"name":"n"}]}`},
+	}
+	for _, tt := range tests {
+		data, err := tt.codec.Marshal(tt.v)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("%+v Marshal(%v) = %s, %v, want %s", tt.codec, tt.v, data, err, tt.want)
+		}
+	}
+}
+
+func TestStdJSONCodecUnmarshal(t *testing.T) {
+	tests := []struct {
+		codec StdJSONCodec
+		data  string
+		ok    bool
+	}{
+		{StdJSONCodec{}, `{"name":"n"}`, true},
+		{StdJSONCodec{}, `{"name":"n"}garbage`, false},
+		{StdJSONCodec{}, `{"name":"n","extra":1}`, true},
+		{StdJSONCodec{DisallowUnknownFields: true}, `{"name":"n","extra":1}`, false},
+		{StdJSONCodec{DisallowUnknownFields: true}, "{\"name\":\"n\"} \n", true},
+		{StdJSONCodec{DisallowUnknownFields: true}, `{"name":"n"}garbage`, false},
+		{StdJSONCodec{UseNumber: true}, `{"name":"n"}{}`, false},
+		{StdJSONCodec{UseNumber: true}, `{"name":"n"} 1`, false},
+	}
+	for _, tt := range tests {
+		var user codecUser
+		if err := tt.codec.Unmarshal([]byte(tt.data), &user); (err == nil) != tt.ok {
+			t.Errorf("%+v Unmarshal(%q) = %v, want ok %v", tt.codec, tt.data, err, tt.ok)
+		}
+	}
+
+	var v map[string]interface{}
+	codec := StdJSONCodec{UseNumber: true}
+	if err := codec.Unmarshal([]byte(`{"n":1.5}`), &v); err != nil || v["n"] != json.Number("1.5") {
+		t.Errorf("UseNumber Unmarshal = %v, %v, want json.Number 1.5", v, err)
+	}
+}
+
+func TestServerJSONCodec(t *testing.T) {
+	s := New()
+	s.SetJSONCodec(&StdJSONCodec{SortKeys: true, DisallowUnknownFields: true})
+	var bindErr error
+	s.POST("/users", func(c *Context) {
+		var user codecUser
+		bindErr = c.BindJSON(&user)
+		c.JSON(http.StatusOK, Result{Data: user})
+	})
+	s.buildTrees()
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"n"}`))
+	w := do(s, req)
+	if want := `{"code":0,"data":{"age":0,"name":"n"},"message":""}`; w.Body.String() != want || bindErr != nil {
+		t.Errorf("JSON = %s, bind err %v, want %s", w.Body.String(), bindErr, want)
+	}
+
+	do(s, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"n","extra":1}`)))
+	if bindErr == nil {
+		t.Errorf("BindJSON with unknown field = nil, want error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SetJSONCodec(nil) did not panic")
+		}
+	}()
+	s.SetJSONCodec(nil)
+}
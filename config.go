@@ -5,8 +5,11 @@
 package nets
 
 import (
+	"net/http"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 )
 
 const (
@@ -24,6 +27,8 @@ const (
 	defaultPriority int = 2 << 10
 	// defaultMultipartMemory multipart表单最大数据量
 	defaultMultipartMemory int64 = 32 << 20 // 32 MB
+	// defaultBodyCacheMax Context.Body缓存请求体的最大长度
+	defaultBodyCacheMax int64 = 10 << 20 // 10 MB
 	// nets env 环境变量名
 	envVarName string = "NETS_ENV"
 	// 默认的HTTP Server Addr
@@ -46,10 +51,56 @@ type Configure struct {
 	forwardedByClientIP bool
 	// 表单数据最大内存值
 	multipartMemoryMax int64
+	// Context.Body缓存请求体的最大长度
+	bodyCacheMax int64
 	// 是否记录trace result data
 	recordResultData bool
+	// trace记录的请求参数最大长度，小于等于0时不限制
+	traceParamsMaxSize int
+	// trace不解析请求体记录参数的Content-Type
+	traceSkipContentTypes []string
+	// trace采样比例，0~1
+	traceSampleRatio float64
+	// 未被采样的请求出错时是否仍然记录trace
+	traceSampleErrors bool
+	// 未被采样的请求耗时超出该值时仍然记录trace，小于等于0时不启用
+	traceSlowThreshold time.Duration
+	// trace脱敏规则
+	traceRedactor traceRedactor
+	// 是否记录每个handler的耗时
+	profiling bool
 	// 是否使用自定义recovery
 	customRecovery bool
+	// 优雅关闭时停止接收新连接前的等待时间，使负载均衡感知就绪检查失败
+	shutdownDelay time.Duration
+	// 是否允许挂载admin路由，默认仅在debug环境下开启
+	admin bool
+	// 是否通过SetAdmin显式设置了admin，显式设置后SetEnv不再修改
+	adminSet bool
+	// debug环境下是否格式化输出json
+	prettyJSON bool
+	// cookie默认Path
+	cookiePath string
+	// cookie默认Domain
+	cookieDomain string
+	// cookie默认Secure
+	cookieSecure bool
+	// 是否通过SetCookieSecure显式设置了cookieSecure，显式设置后SetEnv不再修改
+	cookieSecureSet bool
+	// cookie默认HttpOnly
+	cookieHTTPOnly bool
+	// cookie默认SameSite
+	cookieSameSite http.SameSite
+	// cookie签名/加密密钥，第一个用于签名/加密，全部用于校验/解密
+	cookieKeys []cookieKey
+	// 是否检测请求结束后仍被使用的context（开启后context不再复用）
+	detectContextLeak bool
+	// 检测到请求结束后仍使用context时是否panic（默认打印警告）
+	contextGuardPanic bool
+	// 响应数据序列化失败时的http status
+	renderFallbackStatus int
+	// 响应数据序列化失败时的响应数据
+	renderFallbackResult Result
 }
 
 // newConfig return new config
@@ -60,7 +111,23 @@ func newConfig() *Configure {
 		customRecovery:      false,
 		forwardedByClientIP: true,
 		multipartMemoryMax:  defaultMultipartMemory,
+		bodyCacheMax:        defaultBodyCacheMax,
 		recordResultData:    false,
+		traceSampleRatio:    1,
+		traceSampleErrors:   true,
+		traceRedactor:       newTraceRedactor(),
+		traceSkipContentTypes: []string{
+			"multipart/form-data",
+			"application/octet-stream",
+		},
+		cookiePath:           "/",
+		cookieHTTPOnly:       true,
+		cookieSameSite:       http.SameSiteLaxMode,
+		renderFallbackStatus: http.StatusInternalServerError,
+		renderFallbackResult: Result{
+			Code:    http.StatusInternalServerError,
+			Message: http.StatusText(http.StatusInternalServerError),
+		},
 	}
 	config.SetEnv(os.Getenv(envVarName))
 	return config
@@ -84,6 +151,24 @@ func (config *Configure) SetEnv(env string) {
 	default:
 		panic("unknown env: " + env)
 	}
+	if !config.cookieSecureSet {
+		config.cookieSecure = !config.debug
+	}
+	if !config.adminSet {
+		config.admin = config.debug
+	}
+}
+
+// SetShutdownDelay 设置Server.Shutdown时停止接收新连接前的等待时间，
+// 期间就绪检查返回503，负载均衡（如Kubernetes）将流量切走后再关闭，默认0
+func (config *Configure) SetShutdownDelay(delay time.Duration) {
+	config.shutdownDelay = delay
+}
+
+// SetAdmin 设置是否允许Server.Admin挂载admin路由，默认仅在debug环境下开启
+func (config *Configure) SetAdmin(yesorno bool) {
+	config.admin = yesorno
+	config.adminSet = true
 }
 
 // SetMultipartMemoryMax set multipartMemoryMax
@@ -91,7 +176,168 @@ func (config *Configure) SetMultipartMemoryMax(max int64) {
 	config.multipartMemoryMax = max
 }
 
+// SetBodyCacheMax 设置Context.Body缓存请求体的最大长度，超出时响应413，默认10MB，小于等于0时不限制
+func (config *Configure) SetBodyCacheMax(max int64) {
+	config.bodyCacheMax = max
+}
+
+// SetProfiling 设置是否记录每个handler的耗时（含函数名称），结果保存在Context.Trace.Timings并输出到访问日志
+// 每个handler多两次time.Now调用，建议仅在排查性能问题时开启
+func (config *Configure) SetProfiling(yesorno bool) {
+	config.profiling = yesorno
+}
+
 // SetRecordResultData 设置是否记录影响结果数据
 func (config *Configure) SetRecordResultData(yesorno bool) {
 	config.recordResultData = yesorno
 }
+
+// SetRenderFallback 设置响应数据序列化失败时输出的http status和数据
+func (config *Configure) SetRenderFallback(status int, data Result) {
+	config.renderFallbackStatus = status
+	config.renderFallbackResult = data
+}
+
+// SetPrettyJSON 设置debug环境下是否格式化输出json
+func (config *Configure) SetPrettyJSON(yesorno bool) {
+	config.prettyJSON = yesorno
+}
+
+// SetCookiePath 设置cookie默认Path
+func (config *Configure) SetCookiePath(path string) {
+	config.cookiePath = path
+}
+
+// SetCookieDomain 设置cookie默认Domain
+func (config *Configure) SetCookieDomain(domain string) {
+	config.cookieDomain = domain
+}
+
+// SetCookieSecure 设置cookie默认Secure，默认在非debug环境下开启
+func (config *Configure) SetCookieSecure(yesorno bool) {
+	config.cookieSecure = yesorno
+	config.cookieSecureSet = true
+}
+
+// SetCookieHTTPOnly 设置cookie默认HttpOnly
+func (config *Configure) SetCookieHTTPOnly(yesorno bool) {
+	config.cookieHTTPOnly = yesorno
+}
+
+// SetCookieSameSite 设置cookie默认SameSite
+func (config *Configure) SetCookieSameSite(sameSite http.SameSite) {
+	config.cookieSameSite = sameSite
+}
+
+// SetCookieKeys 设置cookie签名/加密密钥
+// 第一个密钥用于签名和加密，全部密钥用于校验和解密，轮换时将新密钥放在首位
+func (config *Configure) SetCookieKeys(keys ...[]byte) {
+	config.cookieKeys = make([]cookieKey, 0, len(keys))
+	for _, key := range keys {
+		if len(key) == 0 {
+			panic("nets: empty cookie key")
+		}
+		config.cookieKeys = append(config.cookieKeys, newCookieKey(key))
+	}
+}
+
+// SetDetectContextLeak 设置是否检测请求结束后仍被使用的context（通常是goroutine持有了c而非c.Copy()），默认关闭
+// 开启后请求结束的context不再放回sync.Pool，每个请求都会分配新的context，建议仅在开发和测试中开启
+func (config *Configure) SetDetectContextLeak(yesorno bool) {
+	config.detectContextLeak = yesorno
+}
+
+// SetContextGuardPanic 设置检测到请求结束后仍使用context时是否panic，需开启SetDetectContextLeak
+func (config *Configure) SetContextGuardPanic(yesorno bool) {
+	config.contextGuardPanic = yesorno
+}
+
+// SetTraceParamsMaxSize 设置trace记录的请求参数最大长度，小于等于0时不限制
+func (config *Configure) SetTraceParamsMaxSize(max int) {
+	config.traceParamsMaxSize = max
+}
+
+// SetTraceSkipContentTypes 设置trace不主动解析请求体的Content-Type，支持"image/*"形式的通配
+// 默认跳过multipart/form-data和application/octet-stream，handler已读取的表单参数仍会记录
+func (config *Configure) SetTraceSkipContentTypes(contentTypes ...string) {
+	config.traceSkipContentTypes = contentTypes
+}
+
+// SetTraceSampleRatio 设置trace采样比例（0~1），默认1即全部记录
+// 按trace id决定是否采样，请求带有traceparent时沿用上游的采样标记；
+// 未被采样的请求Trace.Sampled为false，不记录请求参数、请求头和结果数据，不放入异步trace pipeline，ExportSpans不导出；
+// Server.Trace注册的handler（如访问日志）对每个请求都会执行
+func (config *Configure) SetTraceSampleRatio(ratio float64) {
+	config.traceSampleRatio = ratio
+}
+
+// SetTraceSampleErrors 设置未被采样的请求出错（http status >= 500或记录了错误）时是否仍然完整记录trace，默认开启
+func (config *Configure) SetTraceSampleErrors(yesorno bool) {
+	config.traceSampleErrors = yesorno
+}
+
+// SetTraceSlowThreshold 设置慢请求阈值，未被采样的请求耗时超出该值时仍然完整记录trace，小于等于0时不启用
+func (config *Configure) SetTraceSlowThreshold(threshold time.Duration) {
+	config.traceSlowThreshold = threshold
+}
+
+// SetTraceRedactKeys 设置trace脱敏的参数名、请求头名和结果数据字段名（不区分大小写），替换默认值
+// 默认包括password、secret、token、authorization、cookie等
+func (config *Configure) SetTraceRedactKeys(keys ...string) {
+	config.traceRedactor.setKeys(keys)
+}
+
+// SetTraceRedactPatterns 设置trace脱敏的字段名正则表达式，匹配方式同SetTraceRedactKeys
+func (config *Configure) SetTraceRedactPatterns(patterns ...string) {
+	config.traceRedactor.patterns = make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		config.traceRedactor.patterns = append(config.traceRedactor.patterns, regexp.MustCompile(pattern))
+	}
+}
+
+// SetTraceRedactPaths 设置trace脱敏的字段路径，如"params.card_no"、"headers.X-Token"、"data.user.phone"、"data.list.*.phone"
+// "*"匹配任意一段（包括数组下标）
+func (config *Configure) SetTraceRedactPaths(paths ...string) {
+	config.traceRedactor.paths = make([][]string, 0, len(paths))
+	for _, path := range paths {
+		config.traceRedactor.paths = append(config.traceRedactor.paths, strings.Split(path, "."))
+	}
+}
+
+// SetTraceRedactMask 设置trace脱敏掩码，默认"***"
+func (config *Configure) SetTraceRedactMask(mask string) {
+	config.traceRedactor.mask = mask
+}
+
+// values 返回当前配置值，cookie密钥只返回数量
+func (config *Configure) values() map[string]interface{} {
+	return map[string]interface{}{
+		"env":                   config.env,
+		"debug":                 config.debug,
+		"trace":                 config.trace,
+		"defaultPriority":       config.defaultPriority,
+		"forwardedByClientIP":   config.forwardedByClientIP,
+		"multipartMemoryMax":    config.multipartMemoryMax,
+		"bodyCacheMax":          config.bodyCacheMax,
+		"recordResultData":      config.recordResultData,
+		"traceParamsMaxSize":    config.traceParamsMaxSize,
+		"traceSkipContentTypes": config.traceSkipContentTypes,
+		"traceSampleRatio":      config.traceSampleRatio,
+		"traceSampleErrors":     config.traceSampleErrors,
+		"traceSlowThreshold":    config.traceSlowThreshold.String(),
+		"profiling":             config.profiling,
+		"customRecovery":        config.customRecovery,
+		"admin":                 config.admin,
+		"shutdownDelay":         config.shutdownDelay.String(),
+		"prettyJSON":            config.prettyJSON,
+		"cookiePath":            config.cookiePath,
+		"cookieDomain":          config.cookieDomain,
+		"cookieSecure":          config.cookieSecure,
+		"cookieHTTPOnly":        config.cookieHTTPOnly,
+		"cookieSameSite":        config.cookieSameSite,
+		"cookieKeys":            len(config.cookieKeys),
+		"detectContextLeak":     config.detectContextLeak,
+		"contextGuardPanic":     config.contextGuardPanic,
+		"renderFallbackStatus":  config.renderFallbackStatus,
+	}
+}
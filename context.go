@@ -5,13 +5,18 @@
 package nets
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"net"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,28 +24,20 @@ const (
 	abortIndex int8 = math.MaxUint8 / 2
 	// ContentTypeJSON json Content-Type
 	ContentTypeJSON string = "application/json; charset=UTF-8"
+	// ContentTypeXML xml Content-Type
+	ContentTypeXML string = "application/xml; charset=UTF-8"
+	// ContentTypeMsgPack msgpack Content-Type
+	ContentTypeMsgPack string = "application/x-msgpack"
 )
 
+// ErrCopiedContext 使用Copy返回的Context输出响应
+var ErrCopiedContext = errors.New("nets: cannot write response with a copied context")
+
 // Result api response result
 type Result struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Data    Any    `json:"data"`
-}
-
-// trace context trace
-type trace struct {
-	Env       string    // runtime env
-	StartTime time.Time // context start time
-	EndTime   time.Time // context end time
-	Cost      int64     // response time (microtime)
-	ClientIP  string    // client ip
-	Params    Any       // request params
-	Status    int       // http status code
-	Code      int       // result code
-	Message   string    // result message
-	Data      Any       // result data
-	Stack     []byte    // error statck
+	Code    int    `json:"code" xml:"code"`
+	Message string `json:"message" xml:"message"`
+	Data    Any    `json:"data" xml:"data"`
 }
 
 // Context net context
@@ -51,19 +48,33 @@ type Context struct {
 	index            int8                         // 下标，记录已执行到的位置
 	handlers         HandlerChain                 // 处理函数数组
 	params           Entries                      // 路由参数
+	fullPath         string                       // 匹配的路由
+	handlerNames     []string                     // 处理函数名称
+	profiling        bool                         // 是否记录各handler耗时
+	childCost        time.Duration                // 当前handler直接调用的handler的累计耗时
 	Keys             map[string]interface{}       // 请求上下文KV
+	Errors           Errors                       // 请求处理过程中收集的错误
 	keysrw           sync.RWMutex                 // Keys读写锁
 	formCacheMaps    map[string]map[string]string // 缓存表单参数
 	formCacheSlices  url.Values                   // 缓存表单参数
 	queryCacheMaps   map[string]map[string]string // 缓存请求参数
 	queryCacheSlices url.Values                   // 缓存请求参数
+	bodyCache        []byte                       // 缓存请求体
+	bodyStreamed     bool                         // 请求体是否已被流式读取
+	heartbeats       []func()                     // 未停止的SSE心跳
+	nameProbe        *string                      // 注册时获取E转换的handler原函数名称
 	Trace            trace                        // context trace data
+	spansMu          sync.Mutex                   // Trace.Spans锁
+	spanGen          uint64                       // trace输出时递增，之前开始的span结束时不再记录
+	copied           bool                         // 是否为Copy返回的只读副本
+	released         int32                        // 请求结束后置为1，打印警告后置为2（仅检测context泄漏时）
 }
 
 // newContext reutrn new *context
 func newContext(s *Server) *Context {
 	return &Context{
 		server: s,
+		index:  -1,
 		params: make(Entries, 0),
 	}
 }
@@ -72,34 +83,101 @@ func newContext(s *Server) *Context {
 func (c *Context) init(w http.ResponseWriter, req *http.Request) {
 	c.Request = req
 	c.responser.reset(w)
+	c.profiling = c.server.Config.profiling
 	if c.server.Config.trace {
 		c.Trace = trace{
 			Env:       c.server.Config.env,
 			StartTime: time.Now(),
 			ClientIP:  c.ClientIP(),
-			Params:    c.Forms(),
 		}
+		c.initTraceContext()
 	}
 }
 
 // reset reset context
 func (c *Context) reset() {
+	c.removeMultipartFiles()
+	c.heartbeats = nil
 	c.index = -1
 	c.Keys = nil
 	c.handlers = nil
 	c.params = nil
+	c.fullPath = ""
+	c.handlerNames = nil
+	c.profiling = false
+	c.childCost = 0
 	c.queryCacheMaps = nil
 	c.queryCacheSlices = nil
+	c.bodyCache = nil
+	c.bodyStreamed = false
 	c.formCacheMaps = nil
 	c.formCacheSlices = nil
+	c.Errors = nil
 	c.Trace = trace{}
 }
 
+// release 标记context对应的请求已结束，之后再使用该context会被guard检测到
+func (c *Context) release() {
+	atomic.StoreInt32(&c.released, 1)
+}
+
+// guard 检测请求结束后仍被使用的context（通常是goroutine持有了c而非c.Copy()）
+// Configure.SetDetectContextLeak(true)时生效，每个context只打印一次警告，Configure.SetContextGuardPanic(true)时panic
+func (c *Context) guard() {
+	if atomic.LoadInt32(&c.released) == 0 {
+		return
+	}
+
+	message := "nets: Context used after its request completed, use Context.Copy() in goroutines"
+	if file, line, ok := externalCaller(); ok {
+		message = fmt.Sprintf("%s (%s:%d)", message, file, line)
+	}
+	if c.server.Config.contextGuardPanic {
+		panic(message)
+	}
+	if atomic.CompareAndSwapInt32(&c.released, 1, 2) {
+		debugPrintf("[WARNING] %s\n", message)
+	}
+}
+
+// externalCaller 返回调用栈中第一个本包源文件之外的调用位置
+func externalCaller() (file string, line int, ok bool) {
+	_, self, _, _ := runtime.Caller(0)
+	dir := filepath.Dir(self)
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != dir || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File, frame.Line, frame.File != ""
+		}
+		if !more {
+			return "", 0, false
+		}
+	}
+}
+
+// writable 检测context是否可以输出响应
+func (c *Context) writable() bool {
+	c.guard()
+	if c.copied {
+		debugPrintf("[WARNING] %v\n", ErrCopiedContext)
+		return false
+	}
+	return true
+}
+
 // Next 按index执行handler
 func (c *Context) Next() {
+	c.guard()
 	c.index++
 	for int(c.index) < len(c.handlers) {
-		c.handlers[c.index](c)
+		if c.profiling {
+			c.profileHandler(c.index)
+		} else {
+			c.handlers[c.index](c)
+		}
 		if !c.IsAborted() {
 			c.index++
 		}
@@ -108,17 +186,22 @@ func (c *Context) Next() {
 
 // IsAborted returns true if the current context was aborted.
 func (c *Context) IsAborted() bool {
+	c.guard()
 	return c.index >= abortIndex
 }
 
 // Abort 终止执行后续handler
 func (c *Context) Abort() {
+	c.guard()
 	c.index = abortIndex
 }
 
 // AbortStatus 终止执行后续handler，并设置http status
 func (c *Context) AbortStatus(code int) {
 	c.Abort()
+	if !c.writable() {
+		return
+	}
 	c.responser.WriteHeader(code)
 	c.responser.WriteHeaderNow()
 	if c.server.Config.trace {
@@ -127,28 +210,51 @@ func (c *Context) AbortStatus(code int) {
 }
 
 // AbortJSON 终止执行后续handler，并响应输出json格式数据
-func (c *Context) AbortJSON(code int, data Result) {
+func (c *Context) AbortJSON(code int, data Result) error {
 	c.Abort()
-	c.JSON(code, data)
+	return c.JSON(code, data)
 }
 
 // JSON 响应输出json格式数据
 func (c *Context) JSON(code int, data Result) (err error) {
+	return c.render(code, ContentTypeJSON, data, c.server.marshalJSON)
+}
+
+// XML 响应输出xml格式数据
+func (c *Context) XML(code int, data Result) (err error) {
+	return c.render(code, ContentTypeXML, data, marshalXML)
+}
+
+// MsgPack 响应输出msgpack格式数据
+func (c *Context) MsgPack(code int, data Result) (err error) {
+	return c.render(code, ContentTypeMsgPack, data, marshalMsgPack)
+}
+
+// render 序列化data并响应输出
+// 先序列化再写入响应，序列化失败时响应配置的fallback数据并返回错误
+func (c *Context) render(code int, contentType string, data Result, marshal func(interface{}) ([]byte, error)) (err error) {
+	if !c.writable() {
+		return ErrCopiedContext
+	}
+
 	if data.Data == nil {
 		// Set the default value of API Result Data to empty AnyMap
 		data.Data = AnyMap{}
 	}
 
-	bytes, err := json.Marshal(data)
+	bytes, err := marshal(data)
 	if err != nil {
-		panic(err)
-	}
-
-	c.SetResponseHeader("Content-Type", ContentTypeJSON)
-	c.responser.WriteHeader(code)
-	c.responser.WriteHeaderNow()
-	if _, err = c.responser.Write(bytes); err != nil {
-		panic("cannot write message to writer during serve error: " + err.Error())
+		debugPrintf("[ERROR] cannot marshal result: %v\n", err)
+		c.Error(err).SetType(ErrorTypeRender)
+		code, data = c.server.Config.renderFallbackStatus, c.server.Config.renderFallbackResult
+		if data.Data == nil {
+			data.Data = AnyMap{}
+		}
+		var fallbackErr error
+		if bytes, fallbackErr = marshal(data); fallbackErr != nil {
+			c.AbortStatus(code)
+			return
+		}
 	}
 
 	if c.server.Config.trace {
@@ -160,21 +266,64 @@ func (c *Context) JSON(code int, data Result) (err error) {
 		}
 	}
 
+	c.SetResponseHeader("Content-Type", contentType)
+	c.responser.WriteHeader(code)
+	c.responser.WriteHeaderNow()
+	if _, writeErr := c.responser.Write(bytes); writeErr != nil {
+		// 客户端断开连接仅记录trace，不作为服务端错误打印
+		c.Abort()
+		c.Error(writeErr).SetType(ErrorTypeRender)
+		if !isBrokenPipe(writeErr) {
+			debugPrintf("[ERROR] cannot write message to writer during serve: %v\n", writeErr)
+		}
+		err = writeErr
+	}
+
 	return
 }
 
+// TraceJSON 使用Server的JSONCodec序列化trace数据
+func (c *Context) TraceJSON() ([]byte, error) {
+	c.guard()
+	return c.server.json.Marshal(c.Trace)
+}
+
+// FullPath 返回匹配的路由（注册时的完整路由，如/users/:id），未匹配到路由时返回空字符串
+func (c *Context) FullPath() string {
+	c.guard()
+	return c.fullPath
+}
+
+// HandlerName 返回路由处理函数（最后一个handler）的名称，如main.getUser
+func (c *Context) HandlerName() string {
+	c.guard()
+	if n := len(c.handlerNames); n > 0 {
+		return c.handlerNames[n-1]
+	}
+	return ""
+}
+
+// HandlerNames 返回按执行顺序排列的全部中间件和处理函数的名称
+func (c *Context) HandlerNames() []string {
+	c.guard()
+	return append([]string(nil), c.handlerNames...)
+}
+
 // Param 返回路由参数key的值，若key不存在，则第二个返回值为false
 func (c *Context) Param(key string) (string, bool) {
+	c.guard()
 	return c.params.Get(key)
 }
 
 // ParamMust 返回路由参数key的值
 func (c *Context) ParamMust(key string) string {
+	c.guard()
 	return c.params.Key(key)
 }
 
 // Params 返回全部路由参数
 func (c *Context) Params() Entries {
+	c.guard()
 	return c.params
 }
 
@@ -213,6 +362,7 @@ func (c *Context) QueryMustArray(key string, defaults ...[]string) []string {
 
 // QueryMap 返回请求参数key的值(字典)，若key不存在，则第二个返回值为false
 func (c *Context) QueryMap(key string) (map[string]string, bool) {
+	c.guard()
 	if values, ok := c.queryCacheMaps[key]; ok && len(values) > 0 {
 		return values, true
 	}
@@ -253,6 +403,7 @@ func (c *Context) Queries() url.Values {
 
 // initQueryCache 初始化请求参数缓存
 func (c *Context) initQueryCache() {
+	c.guard()
 	if c.queryCacheSlices == nil {
 		c.queryCacheSlices = c.Request.URL.Query()
 	}
@@ -293,6 +444,7 @@ func (c *Context) FormMustArray(key string, defaults ...[]string) []string {
 
 // FormMap 返回表单参数key的值(字典)，若key不存在，则第二个返回值为false
 func (c *Context) FormMap(key string) (map[string]string, bool) {
+	c.guard()
 	if values, ok := c.formCacheMaps[key]; ok && len(values) > 0 {
 		return values, true
 	}
@@ -333,8 +485,10 @@ func (c *Context) Forms() url.Values {
 
 // initFormCache 初始化表单参数缓存
 func (c *Context) initFormCache() {
+	c.guard()
 	if c.formCacheSlices == nil {
 		c.formCacheSlices = make(url.Values)
+		c.rewindBody()
 		if err := c.Request.ParseMultipartForm(c.server.Config.multipartMemoryMax); err != nil {
 			if err != http.ErrNotMultipart {
 				debugPrintf("error on parse multipart form array: %v", err)
@@ -346,6 +500,7 @@ func (c *Context) initFormCache() {
 
 // Set add or update Keys
 func (c *Context) Set(key string, value interface{}) {
+	c.guard()
 	c.keysrw.Lock()
 	if c.Keys == nil {
 		c.Keys = make(map[string]interface{})
@@ -356,6 +511,7 @@ func (c *Context) Set(key string, value interface{}) {
 
 // Get return the value of the key in Keys
 func (c *Context) Get(key string) (interface{}, bool) {
+	c.guard()
 	c.keysrw.RLock()
 	value, ok := c.Keys[key]
 	c.keysrw.RUnlock()
@@ -370,8 +526,95 @@ func (c *Context) Key(key string, defaults ...interface{}) interface{} {
 	return IndexOf(defaults, 0, nil)
 }
 
+// Copy 返回当前Context的只读快照（请求、路由参数、Keys、请求参数缓存和trace），
+// 可在handler返回后于goroutine中安全使用；快照不包含handlers，也不能用于输出响应
+func (c *Context) Copy() *Context {
+	c.guard()
+	cp := &Context{
+		server:           c.server,
+		Request:          c.Request,
+		index:            abortIndex,
+		fullPath:         c.fullPath,
+		handlerNames:     c.handlerNames,
+		formCacheMaps:    c.formCacheMaps,
+		formCacheSlices:  c.formCacheSlices,
+		queryCacheMaps:   c.queryCacheMaps,
+		queryCacheSlices: c.queryCacheSlices,
+		bodyCache:        c.bodyCache,
+		Errors:           append(Errors(nil), c.Errors...),
+		Trace:            c.Trace,
+		copied:           true,
+	}
+
+	cp.params = make(Entries, len(c.params))
+	copy(cp.params, c.params)
+
+	c.spansMu.Lock()
+	cp.Trace.Spans = append([]*Span(nil), c.Trace.Spans...)
+	c.spansMu.Unlock()
+
+	c.keysrw.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.keysrw.RUnlock()
+
+	return cp
+}
+
+// Deadline 实现context.Context接口，返回请求context的deadline
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	c.guard()
+	if c.Request == nil {
+		return
+	}
+	return c.Request.Context().Deadline()
+}
+
+// Done 实现context.Context接口，请求结束或客户端断开时关闭
+func (c *Context) Done() <-chan struct{} {
+	c.guard()
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Done()
+}
+
+// Err 实现context.Context接口
+func (c *Context) Err() error {
+	c.guard()
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Err()
+}
+
+// Value 实现context.Context接口
+// key为string时优先返回Keys中的值，否则返回请求context中的值
+func (c *Context) Value(key interface{}) interface{} {
+	c.guard()
+	if k, ok := key.(string); ok {
+		if value, ok := c.Get(k); ok {
+			return value
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Value(key)
+}
+
+// interface guard
+var _ context.Context = (*Context)(nil)
+
 // SetResponseHeader 设置请求头
 func (c *Context) SetResponseHeader(key, value string) {
+	if !c.writable() {
+		return
+	}
 	if value == "" {
 		c.responser.Header().Del(key)
 		return
@@ -381,6 +624,7 @@ func (c *Context) SetResponseHeader(key, value string) {
 
 // ClientIP return the request client ip
 func (c *Context) ClientIP() string {
+	c.guard()
 	if c.server.Config.forwardedByClientIP {
 		xForwardedFor := c.Request.Header.Get("X-Forwarded-For")
 		ip := strings.TrimSpace(strings.Split(xForwardedFor, ",")[0])
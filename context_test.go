@@ -0,0 +1,159 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ctxKey string
+
+func TestContextValue(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey("rid"), "r1")
+	ctx = context.WithValue(ctx, "user", "from request")
+	ctx = context.WithValue(ctx, "role", "admin")
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	c, _ := testContext(New(), req)
+	c.Set("user", "from keys")
+
+	tests := []struct {
+		key  interface{}
+		want interface{}
+	}{
+		{"user", "from keys"},
+		{"role", "admin"},
+		{ctxKey("rid"), "r1"},
+		{ctxKey("user"), nil},
+		{"none", nil},
+	}
+	for _, tt := range tests {
+		if got := c.Value(tt.key); got != tt.want {
+			t.Errorf("Value(%v) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	// Context可作为context.Context传递
+	if got := context.WithValue(c, ctxKey("k"), 1).Value("user"); got != "from keys" {
+		t.Errorf("derived context Value(user) = %v", got)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	c, _ := testContext(New(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if got, ok := c.Deadline(); !ok || !got.Equal(deadline) {
+		t.Errorf("Deadline = %v, %v, want %v", got, ok, deadline)
+	}
+	if c.Err() != nil {
+		t.Errorf("Err before cancel = %v", c.Err())
+	}
+	cancel()
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after cancel")
+	}
+	if c.Err() != context.Canceled {
+		t.Errorf("Err after cancel = %v, want context.Canceled", c.Err())
+	}
+}
+
+func TestCopy(t *testing.T) {
+	s := New()
+	copies := make(chan *Context, 1)
+	s.GET("/users/:id", func(c *Context) {
+		c.Set("user", c.ParamMust("id"))
+		copies <- c.Copy()
+	})
+	s.buildTrees()
+
+	serve(s, http.MethodGet, "/users/1")
+	cp := <-copies
+	// 原Context放回pool后被其他请求复用，副本不受影响
+	serve(s, http.MethodGet, "/users/2")
+	<-copies
+
+	if id, _ := cp.Param("id"); id != "1" {
+		t.Errorf("copy Param(id) = %s, want 1", id)
+	}
+	if user, _ := cp.Get("user"); user != "1" {
+		t.Errorf("copy Get(user) = %v, want 1", user)
+	}
+	if cp.Request.URL.Path != "/users/1" {
+		t.Errorf("copy Request path = %s, want /users/1", cp.Request.URL.Path)
+	}
+}
+
+func TestCopyReadOnly(t *testing.T) {
+	s := New()
+	var cp *Context
+	var renderErr error
+	s.GET("/", func(c *Context) {
+		c.Error(errors.New("original"))
+		cp = c.Copy()
+		renderErr = cp.JSON(http.StatusTeapot, Result{})
+		cp.SetResponseHeader("X-Copy", "1")
+		cp.Error(errors.New("copy"))
+	})
+	s.buildTrees()
+
+	w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+	if renderErr != ErrCopiedContext {
+		t.Errorf("copy JSON = %v, want ErrCopiedContext", renderErr)
+	}
+	if w.Code == http.StatusTeapot || w.Header().Get("X-Copy") != "" {
+		t.Errorf("copy wrote response: %d, headers %v", w.Code, w.Header())
+	}
+	if len(cp.Errors) != 2 {
+		t.Errorf("copy Errors = %v, want original and copy", cp.Errors)
+	}
+}
+
+func TestContextLeakGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		detect   bool
+		panics   bool
+		released int32 // 请求结束后使用leaked时released的值
+	}{
+		{"disabled", false, false, 0},
+		{"warn once", true, false, 2},
+		{"panic", true, true, 1},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.Config.SetDetectContextLeak(tt.detect)
+		s.Config.SetContextGuardPanic(tt.panics)
+		var leaked *Context
+		s.GET("/users/:id", func(c *Context) { leaked = c })
+		s.buildTrees()
+		serve(s, http.MethodGet, "/users/1")
+
+		var recovered interface{}
+		func() {
+			defer func() { recovered = recover() }()
+			leaked.Param("id")
+			leaked.Param("id")
+		}()
+
+		if (recovered != nil) != tt.panics || atomic.LoadInt32(&leaked.released) != tt.released {
+			t.Errorf("%s: recovered %v, released %d, want panic %v, released %d",
+				tt.name, recovered, leaked.released, tt.panics, tt.released)
+		}
+		// panic信息指向本包之外的调用位置
+		if message, _ := recovered.(string); tt.panics && !strings.Contains(message, "context_test.go:") {
+			t.Errorf("%s: panic message %q does not name the caller", tt.name, message)
+		}
+	}
+}
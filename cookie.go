@@ -0,0 +1,235 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoCookieKeys 未配置cookie签名/加密密钥
+	ErrNoCookieKeys = errors.New("nets: cookie keys are not configured")
+	// ErrInvalidCookie cookie被篡改、已过期或无法解密
+	ErrInvalidCookie = errors.New("nets: invalid cookie")
+)
+
+// cookieKey 由配置的密钥派生出的签名密钥和加密密钥
+type cookieKey struct {
+	sign    []byte
+	encrypt []byte
+}
+
+// newCookieKey 使用HMAC-SHA256从key派生签名和加密密钥
+func newCookieKey(key []byte) cookieKey {
+	derive := func(purpose string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(purpose))
+		return mac.Sum(nil)
+	}
+	return cookieKey{sign: derive("nets-cookie-sign"), encrypt: derive("nets-cookie-encrypt")}
+}
+
+// Cookie 返回请求cookie的原始值，若cookie不存在，则第二个返回值为false
+func (c *Context) Cookie(name string) (string, bool) {
+	c.guard()
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// CookieMust 返回请求cookie的值
+func (c *Context) CookieMust(name string, defaults ...string) string {
+	if value, ok := c.Cookie(name); ok {
+		return value
+	}
+	return IndexOfStrings(defaults, 0, "")
+}
+
+// SetCookie 使用Configure中的默认属性设置响应cookie
+// maxAge = 0 表示会话cookie，maxAge < 0 表示删除cookie
+// value原样写入，不能包含分号、双引号、反斜杠和控制字符（会被丢弃），任意数据需先编码（如base64）
+func (c *Context) SetCookie(name, value string, maxAge int) {
+	config := c.server.Config
+	c.SetRawCookie(&http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     config.cookiePath,
+		Domain:   config.cookieDomain,
+		MaxAge:   maxAge,
+		Secure:   config.cookieSecure,
+		HttpOnly: config.cookieHTTPOnly,
+		SameSite: config.cookieSameSite,
+	})
+}
+
+// SetRawCookie 原样设置响应cookie
+func (c *Context) SetRawCookie(cookie *http.Cookie) {
+	c.guard()
+	http.SetCookie(&c.responser, cookie)
+}
+
+// DeleteCookie 删除cookie
+func (c *Context) DeleteCookie(name string) {
+	c.SetCookie(name, "", -1)
+}
+
+// SetSignedCookie 设置HMAC签名的cookie，值可被客户端读取但无法篡改
+func (c *Context) SetSignedCookie(name, value string, maxAge int) error {
+	keys := c.server.Config.cookieKeys
+	if len(keys) == 0 {
+		return ErrNoCookieKeys
+	}
+
+	payload := cookiePayload(value, maxAge)
+	signature := signCookie(keys[0].sign, name, payload)
+	c.SetCookie(name, encodeCookie([]byte(payload))+"."+encodeCookie(signature), maxAge)
+	return nil
+}
+
+// SignedCookie 返回校验签名后的cookie值，若cookie不存在或校验失败，则第二个返回值为false
+// 依次使用全部密钥校验，以支持密钥轮换
+func (c *Context) SignedCookie(name string) (string, bool) {
+	raw, ok := c.Cookie(name)
+	if !ok {
+		return "", false
+	}
+	value, err := c.verifyCookie(name, raw)
+	return value, err == nil
+}
+
+// SetEncryptedCookie 设置AES-GCM加密的cookie，值对客户端不可见且无法篡改
+func (c *Context) SetEncryptedCookie(name, value string, maxAge int) error {
+	keys := c.server.Config.cookieKeys
+	if len(keys) == 0 {
+		return ErrNoCookieKeys
+	}
+
+	aead, err := newCookieAEAD(keys[0].encrypt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(cookiePayload(value, maxAge)), []byte(name))
+	c.SetCookie(name, encodeCookie(sealed), maxAge)
+	return nil
+}
+
+// EncryptedCookie 返回解密后的cookie值，若cookie不存在或解密失败，则第二个返回值为false
+// 依次使用全部密钥解密，以支持密钥轮换
+func (c *Context) EncryptedCookie(name string) (string, bool) {
+	raw, ok := c.Cookie(name)
+	if !ok {
+		return "", false
+	}
+	value, err := c.decryptCookie(name, raw)
+	return value, err == nil
+}
+
+// verifyCookie 校验签名cookie并返回原始值
+func (c *Context) verifyCookie(name, raw string) (string, error) {
+	i := strings.LastIndexByte(raw, '.')
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	payload, err := decodeCookie(raw[:i])
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	signature, err := decodeCookie(raw[i+1:])
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range c.server.Config.cookieKeys {
+		if hmac.Equal(signature, signCookie(key.sign, name, string(payload))) {
+			return parseCookiePayload(string(payload))
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// decryptCookie 解密cookie并返回原始值
+func (c *Context) decryptCookie(name, raw string) (string, error) {
+	sealed, err := decodeCookie(raw)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+
+	for _, key := range c.server.Config.cookieKeys {
+		aead, err := newCookieAEAD(key.encrypt)
+		if err != nil || len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return parseCookiePayload(string(plaintext))
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// cookiePayload 将过期时间与值拼接，maxAge <= 0 时不设置过期时间
+func cookiePayload(value string, maxAge int) string {
+	var expires int64
+	if maxAge > 0 {
+		expires = time.Now().Add(time.Duration(maxAge) * time.Second).Unix()
+	}
+	return strconv.FormatInt(expires, 10) + "|" + value
+}
+
+// parseCookiePayload 解析cookiePayload，已过期时返回ErrInvalidCookie
+func parseCookiePayload(payload string) (string, error) {
+	i := strings.IndexByte(payload, '|')
+	if i < 0 {
+		return "", ErrInvalidCookie
+	}
+	expires, err := strconv.ParseInt(payload[:i], 10, 64)
+	if err != nil || (expires > 0 && time.Now().Unix() > expires) {
+		return "", ErrInvalidCookie
+	}
+	return payload[i+1:], nil
+}
+
+// signCookie 计算cookie签名，签名包含cookie名称以防止cookie间互换
+func signCookie(key []byte, name, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name + "|" + payload))
+	return mac.Sum(nil)
+}
+
+// newCookieAEAD return AES-GCM cipher
+func newCookieAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encodeCookie base64 url encode
+func encodeCookie(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCookie base64 url decode
+func decodeCookie(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testContext 返回处理req的Context，响应写入返回的recorder
+func testContext(s *Server, req *http.Request) (*Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c := newContext(s)
+	c.init(w, req)
+	return c, w
+}
+
+// cookieServer 返回配置了cookie密钥的Server
+func cookieServer(keys ...string) *Server {
+	s := New()
+	if len(keys) > 0 {
+		bytes := make([][]byte, 0, len(keys))
+		for _, key := range keys {
+			bytes = append(bytes, []byte(key))
+		}
+		s.Config.SetCookieKeys(bytes...)
+	}
+	return s
+}
+
+// issueCookie 调用set设置cookie并返回响应中名为name的cookie
+func issueCookie(s *Server, name string, set func(c *Context) error) (*http.Cookie, error) {
+	c, w := testContext(s, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err := set(c); err != nil {
+		return nil, err
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie, nil
+		}
+	}
+	return nil, nil
+}
+
+// readCookie 携带cookie发起请求并调用get读取cookie
+func readCookie(s *Server, cookie *http.Cookie, get func(c *Context) (string, bool)) (string, bool) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	c, _ := testContext(s, req)
+	return get(c)
+}
+
+func TestSignedCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string // 读取时的密钥，签名使用"old"
+		tamper func(cookie *http.Cookie)
+		maxAge int
+		value  string
+		ok     bool
+	}{
+		{"valid", []string{"old"}, nil, 3600, "user=1; id", true},
+		{"session", []string{"old"}, nil, 0, "nets", true},
+		{"rotated", []string{"new", "old"}, nil, 3600, "nets", true},
+		{"retired key", []string{"new"}, nil, 3600, "", false},
+		{"payload tampered", []string{"old"}, func(cookie *http.Cookie) {
+			cookie.Value = encodeCookie([]byte("0|admin")) + cookie.Value[strings.LastIndexByte(cookie.Value, '.'):]
+		}, 3600, "", false},
+		{"renamed", []string{"old"}, func(cookie *http.Cookie) { cookie.Name = "other" }, 3600, "", false},
+		{"no signature", []string{"old"}, func(cookie *http.Cookie) { cookie.Value = encodeCookie([]byte("0|nets")) }, 3600, "", false},
+		{"expired", []string{"old"}, func(cookie *http.Cookie) {
+			payload := "1|nets"
+			cookie.Value = encodeCookie([]byte(payload)) + "." + encodeCookie(signCookie(newCookieKey([]byte("old")).sign, "sid", payload))
+		}, 3600, "", false},
+	}
+	for _, tt := range tests {
+		value := "nets"
+		if tt.value != "" {
+			value = tt.value
+		}
+		cookie, err := issueCookie(cookieServer("old"), "sid", func(c *Context) error {
+			return c.SetSignedCookie("sid", value, tt.maxAge)
+		})
+		if err != nil || cookie == nil {
+			t.Fatalf("%s: SetSignedCookie = %v, cookie %v", tt.name, err, cookie)
+		}
+		if tt.tamper != nil {
+			tt.tamper(cookie)
+		}
+
+		got, ok := readCookie(cookieServer(tt.keys...), cookie, func(c *Context) (string, bool) {
+			return c.SignedCookie("sid")
+		})
+		if ok != tt.ok || (ok && got != value) {
+			t.Errorf("%s: SignedCookie = %q, %v, want %q, %v", tt.name, got, ok, value, tt.ok)
+		}
+	}
+}
+
+func TestEncryptedCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   []string // 读取时的密钥，加密使用"old"
+		tamper func(cookie *http.Cookie)
+		ok     bool
+	}{
+		{"valid", []string{"old"}, nil, true},
+		{"rotated", []string{"new", "old"}, nil, true},
+		{"retired key", []string{"new"}, nil, false},
+		{"tampered", []string{"old"}, func(cookie *http.Cookie) {
+			sealed, _ := decodeCookie(cookie.Value)
+			sealed[len(sealed)-1] ^= 1
+			cookie.Value = encodeCookie(sealed)
+		}, false},
+		{"truncated", []string{"old"}, func(cookie *http.Cookie) { cookie.Value = cookie.Value[:8] }, false},
+		{"renamed", []string{"old"}, func(cookie *http.Cookie) { cookie.Name = "other" }, false},
+		{"not base64", []string{"old"}, func(cookie *http.Cookie) { cookie.Value = "!!" }, false},
+	}
+	for _, tt := range tests {
+		cookie, err := issueCookie(cookieServer("old"), "sid", func(c *Context) error {
+			return c.SetEncryptedCookie("sid", "secret value", 3600)
+		})
+		if err != nil || cookie == nil {
+			t.Fatalf("%s: SetEncryptedCookie = %v, cookie %v", tt.name, err, cookie)
+		}
+		if raw, _ := decodeCookie(cookie.Value); strings.Contains(string(raw), "secret") {
+			t.Fatalf("%s: encrypted cookie exposes value: %q", tt.name, raw)
+		}
+		if tt.tamper != nil {
+			tt.tamper(cookie)
+		}
+
+		got, ok := readCookie(cookieServer(tt.keys...), cookie, func(c *Context) (string, bool) {
+			return c.EncryptedCookie("sid")
+		})
+		if ok != tt.ok || (ok && got != "secret value") {
+			t.Errorf("%s: EncryptedCookie = %q, %v, want %v", tt.name, got, ok, tt.ok)
+		}
+	}
+}
+
+func TestCookieKeysRequired(t *testing.T) {
+	s := cookieServer()
+	for _, set := range []func(c *Context) error{
+		func(c *Context) error { return c.SetSignedCookie("sid", "nets", 0) },
+		func(c *Context) error { return c.SetEncryptedCookie("sid", "nets", 0) },
+	} {
+		if _, err := issueCookie(s, "sid", set); err != ErrNoCookieKeys {
+			t.Errorf("set cookie without keys = %v, want ErrNoCookieKeys", err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SetCookieKeys with empty key did not panic")
+		}
+	}()
+	s.Config.SetCookieKeys([]byte("key"), nil)
+}
+
+func TestSetCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(config *Configure)
+		set    func(c *Context)
+		header string
+	}{
+		{"defaults", func(config *Configure) {}, func(c *Context) { c.SetCookie("a", "b=c", 0) }, "a=b=c; Path=/; HttpOnly; SameSite=Lax"},
+		{"release secure", func(config *Configure) { config.SetEnv(EnvRelease) }, func(c *Context) { c.SetCookie("a", "b", 60) },
+			"a=b; Path=/; Max-Age=60; HttpOnly; Secure; SameSite=Lax"},
+		{"explicit secure kept", func(config *Configure) { config.SetCookieSecure(false); config.SetEnv(EnvRelease) },
+			func(c *Context) { c.SetCookie("a", "b", 0) }, "a=b; Path=/; HttpOnly; SameSite=Lax"},
+		{"attributes", func(config *Configure) {
+			config.SetCookiePath("/api")
+			config.SetCookieDomain("example.com")
+			config.SetCookieHTTPOnly(true)
+			config.SetCookieSameSite(http.SameSiteStrictMode)
+		}, func(c *Context) { c.SetCookie("a", "b", 0) }, "a=b; Path=/api; Domain=example.com; HttpOnly; SameSite=Strict"},
+		{"delete", func(config *Configure) {}, func(c *Context) { c.DeleteCookie("a") }, "a=; Path=/; Max-Age=0; HttpOnly; SameSite=Lax"},
+		{"raw", func(config *Configure) { config.SetCookiePath("/api") },
+			func(c *Context) { c.SetRawCookie(&http.Cookie{Name: "a", Value: "b"}) }, "a=b"},
+	}
+	for _, tt := range tests {
+		s := New()
+		tt.config(s.Config)
+		c, w := testContext(s, httptest.NewRequest(http.MethodGet, "/", nil))
+		tt.set(c)
+		if got := w.Header().Get("Set-Cookie"); got != tt.header {
+			t.Errorf("%s: Set-Cookie = %q, want %q", tt.name, got, tt.header)
+		}
+	}
+}
+
+func TestCookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", "a=1; b=x%20y")
+	c, _ := testContext(New(), req)
+
+	if value, ok := c.Cookie("b"); !ok || value != "x%20y" {
+		t.Errorf("Cookie(b) = %q, %v, want verbatim value", value, ok)
+	}
+	if value := c.CookieMust("none", "default"); value != "default" {
+		t.Errorf("CookieMust(none) = %q, want default", value)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrorType 错误类型
+type ErrorType uint8
+
+const (
+	// ErrorTypePrivate 内部错误，错误信息不对客户端输出
+	ErrorTypePrivate ErrorType = 1 << iota
+	// ErrorTypePublic 公开错误，错误信息可对客户端输出
+	ErrorTypePublic
+	// ErrorTypeBind 请求参数绑定/校验错误
+	ErrorTypeBind
+	// ErrorTypeRender 响应输出错误
+	ErrorTypeRender
+	// ErrorTypeAny 任意类型
+	ErrorTypeAny ErrorType = 1<<8 - 1
+)
+
+// Error 请求处理过程中收集的错误
+type Error struct {
+	Err    error     // 原始错误
+	Type   ErrorType // 错误类型
+	Status int       // 映射的http status，为0时按Type映射
+	Code   int       // 映射的Result code，为0时使用http status
+	Meta   Any       // 附加数据
+}
+
+// Errors 错误列表
+type Errors []*Error
+
+// Error 实现error接口
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsType 是否为类型t
+func (e *Error) IsType(t ErrorType) bool {
+	return e.Type&t > 0
+}
+
+// SetType 设置错误类型
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+// SetStatus 设置映射的http status
+func (e *Error) SetStatus(status int) *Error {
+	e.Status = status
+	return e
+}
+
+// SetCode 设置映射的Result code
+func (e *Error) SetCode(code int) *Error {
+	e.Code = code
+	return e
+}
+
+// SetMeta 设置附加数据
+func (e *Error) SetMeta(meta Any) *Error {
+	e.Meta = meta
+	return e
+}
+
+// Last 返回最后一个错误，列表为空时返回nil
+func (errs Errors) Last() *Error {
+	if length := len(errs); length > 0 {
+		return errs[length-1]
+	}
+	return nil
+}
+
+// ByType 返回指定类型的错误
+func (errs Errors) ByType(t ErrorType) Errors {
+	if t == ErrorTypeAny {
+		return errs
+	}
+	var result Errors
+	for _, err := range errs {
+		if err.IsType(t) {
+			result = append(result, err)
+		}
+	}
+	return result
+}
+
+// Strings 返回全部错误信息
+func (errs Errors) Strings() []string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
+
+// String 返回全部错误信息，以换行分隔
+func (errs Errors) String() string {
+	return strings.Join(errs.Strings(), "\n")
+}
+
+// ErrorMapper 将错误映射为http status和响应数据
+type ErrorMapper func(c *Context, err *Error) (status int, data Result)
+
+// DefaultErrorMapper 默认错误映射：
+// Status非0时使用Status；绑定错误映射为400；其他错误映射为500
+// 公开错误和绑定错误输出错误信息，内部错误和输出错误仅输出http status文本
+func DefaultErrorMapper(c *Context, err *Error) (status int, data Result) {
+	status = err.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+		if err.IsType(ErrorTypeBind) {
+			status = http.StatusBadRequest
+		}
+	}
+
+	data = Result{Code: err.Code, Message: http.StatusText(status), Data: err.Meta}
+	if data.Code == 0 {
+		data.Code = status
+	}
+	if err.IsType(ErrorTypePublic | ErrorTypeBind) {
+		data.Message = err.Error()
+	}
+	return
+}
+
+// SetErrorMapper 设置ErrorHandler使用的错误映射
+func (s *Server) SetErrorMapper(mapper ErrorMapper) {
+	if mapper == nil {
+		panic("nets: error mapper is nil")
+	}
+	s.errorMapper = mapper
+}
+
+// ErrorHandler 返回错误处理中间件：后续handler执行完毕后，
+// 若收集到错误且尚未输出响应，则使用Server的ErrorMapper映射最后一个错误并输出json
+func ErrorHandler() HandlerFunc {
+	return func(c *Context) {
+		c.Next()
+		c.handleErrors()
+	}
+}
+
+// handleErrors 映射最后一个错误并输出响应
+func (c *Context) handleErrors() {
+	err := c.Errors.Last()
+	if err == nil || c.responser.Written() {
+		return
+	}
+	status, data := c.server.errorMapper(c, err)
+	c.AbortJSON(status, data)
+}
+
+// Error 收集一个错误并记录到trace，返回的*Error可用于设置错误类型等信息
+// err为*Error时原样收集，否则包装为ErrorTypePrivate类型
+func (c *Context) Error(err error) *Error {
+	c.guard()
+	if err == nil {
+		panic("nets: err is nil")
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Err: err, Type: ErrorTypePrivate}
+	}
+
+	c.Errors = append(c.Errors, e)
+	if c.server.Config.trace {
+		c.Trace.Errors = append(c.Trace.Errors, e.Error())
+	}
+	return e
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler HandlerFunc
+		status  int
+		body    string
+	}{
+		{"no error", func(c *Context) {}, http.StatusOK, ""},
+		{"private", func(c *Context) { c.Error(errors.New("db down")) },
+			http.StatusInternalServerError, `{"code":500,"message":"Internal Server Error","data":{}}`},
+		{"public", func(c *Context) { c.Error(errors.New("not allowed")).SetType(ErrorTypePublic) },
+			http.StatusInternalServerError, `{"code":500,"message":"not allowed","data":{}}`},
+		{"bind", func(c *Context) { c.Error(errors.New("id is required")).SetType(ErrorTypeBind) },
+			http.StatusBadRequest, `{"code":400,"message":"id is required","data":{}}`},
+		{"render", func(c *Context) { c.Error(errors.New("encode")).SetType(ErrorTypeRender) },
+			http.StatusInternalServerError, `{"code":500,"message":"Internal Server Error","data":{}}`},
+		{"status code meta", func(c *Context) {
+			c.Error(errors.New("no user")).SetStatus(http.StatusNotFound).SetCode(1004).SetMeta(AnyMap{"id": 1})
+		}, http.StatusNotFound, `{"code":1004,"message":"Not Found","data":{"id":1}}`},
+		{"last error", func(c *Context) {
+			c.Error(errors.New("first")).SetType(ErrorTypePublic)
+			c.Error(errors.New("second")).SetType(ErrorTypeBind)
+		}, http.StatusBadRequest, `{"code":400,"message":"second","data":{}}`},
+		{"wrapped error", func(c *Context) {
+			c.Error(fmt.Errorf("wrap: %w", &Error{Err: errors.New("inner"), Type: ErrorTypePublic, Status: http.StatusConflict}))
+		}, http.StatusConflict, `{"code":409,"message":"inner","data":{}}`},
+		{"already written", func(c *Context) {
+			c.JSON(http.StatusOK, Result{Message: "ok"})
+			c.Error(errors.New("late"))
+		}, http.StatusOK, `{"code":0,"message":"ok","data":{}}`},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.Use(ErrorHandler())
+		s.GET("/", tt.handler)
+		s.buildTrees()
+
+		w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != tt.status || w.Body.String() != tt.body {
+			t.Errorf("%s: GET / = %d %s, want %d %s", tt.name, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+}
+
+func TestSetErrorMapper(t *testing.T) {
+	s := New()
+	s.SetErrorMapper(func(c *Context, err *Error) (int, Result) {
+		return http.StatusTeapot, Result{Code: len(c.Errors), Message: "custom: " + err.Error()}
+	})
+	s.Use(ErrorHandler())
+	s.GET("/", func(c *Context) {
+		c.Error(errors.New("a"))
+		c.Error(errors.New("b"))
+	})
+	s.buildTrees()
+
+	w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+	if want := `{"code":2,"message":"custom: b","data":{}}`; w.Code != http.StatusTeapot || w.Body.String() != want {
+		t.Errorf("GET / = %d %s, want %d %s", w.Code, w.Body.String(), http.StatusTeapot, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SetErrorMapper(nil) did not panic")
+		}
+	}()
+	s.SetErrorMapper(nil)
+}
+
+func TestErrors(t *testing.T) {
+	c, _ := testContext(New(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if c.Errors.Last() != nil {
+		t.Errorf("Last of empty Errors = %v", c.Errors.Last())
+	}
+
+	c.Error(io.EOF)
+	c.Error(errors.New("bind")).SetType(ErrorTypeBind)
+	c.Error(errors.New("public")).SetType(ErrorTypePublic)
+
+	tests := []struct {
+		t    ErrorType
+		want string
+	}{
+		{ErrorTypePrivate, "EOF"},
+		{ErrorTypeBind, "bind"},
+		{ErrorTypeBind | ErrorTypePublic, "bind,public"},
+		{ErrorTypeRender, ""},
+		{ErrorTypeAny, "EOF,bind,public"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(c.Errors.ByType(tt.t).Strings(), ","); got != tt.want {
+			t.Errorf("ByType(%d) = %s, want %s", tt.t, got, tt.want)
+		}
+	}
+	if got := c.Errors.String(); got != "EOF\nbind\npublic" {
+		t.Errorf("String = %q", got)
+	}
+	if !errors.Is(c.Errors[0], io.EOF) || c.Errors.Last().Error() != "public" {
+		t.Errorf("Errors = %v", c.Errors.Strings())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Error(nil) did not panic")
+		}
+	}()
+	c.Error(nil)
+}
@@ -4,4 +4,4 @@
 
 module nets
 
-go 1.15
+go 1.21
@@ -0,0 +1,238 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthTimeout  = 5 * time.Second
+	defaultHealthCacheTTL = time.Second
+
+	// HealthStatusOK 全部检查通过
+	HealthStatusOK string = "ok"
+	// HealthStatusDegraded 非关键检查失败
+	HealthStatusDegraded string = "degraded"
+	// HealthStatusFail 关键检查失败
+	HealthStatusFail string = "fail"
+	// HealthStatusDraining 正在优雅关闭，不再接收流量
+	HealthStatusDraining string = "draining"
+)
+
+// ErrHealthCheckTimeout 健康检查超时
+var ErrHealthCheckTimeout = errors.New("nets: health check timed out")
+
+// HealthCheck 健康检查
+type HealthCheck struct {
+	// 检查名称，唯一
+	Name string
+	// 检查函数，应在ctx结束时尽快返回
+	Check func(ctx context.Context) error
+	// 超时时间，默认5s
+	Timeout time.Duration
+	// 是否为关键检查，关键检查失败时响应503，非关键检查失败时状态为degraded但仍响应200
+	Critical bool
+	// 是否用于存活检查（/healthz），默认仅用于就绪检查（/readyz），存活检查不宜依赖外部服务
+	Liveness bool
+	// 结果缓存时间，避免频繁探测依赖服务，默认1s，小于0时不缓存
+	CacheTTL time.Duration
+}
+
+// HealthCheckResult 单个检查的结果
+type HealthCheckResult struct {
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Critical  bool          `json:"critical"`
+	Duration  time.Duration `json:"duration"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Cached    bool          `json:"cached"`
+}
+
+// HealthReport 健康检查汇总
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthRegistry 健康检查注册表
+//
+//	s.Health().Register(nets.HealthCheck{Name: "db", Check: db.PingContext, Critical: true})
+//	s.GET("/healthz", s.Health().Healthz())
+//	s.GET("/readyz", s.Health().Readyz())
+type HealthRegistry struct {
+	server *Server
+	mu     sync.RWMutex
+	checks map[string]*healthEntry
+}
+
+// healthEntry 已注册的检查及其缓存结果
+type healthEntry struct {
+	check  HealthCheck
+	mu     sync.Mutex // 同一检查同时只执行一次
+	result HealthCheckResult
+	expire time.Time
+}
+
+// newHealthRegistry return new *HealthRegistry
+func newHealthRegistry(s *Server) *HealthRegistry {
+	return &HealthRegistry{server: s, checks: make(map[string]*healthEntry)}
+}
+
+// Health 返回Server的健康检查注册表
+func (s *Server) Health() *HealthRegistry {
+	return s.health
+}
+
+// Register 注册健康检查，同名检查会被替换
+func (h *HealthRegistry) Register(check HealthCheck) {
+	if check.Name == "" || check.Check == nil {
+		panic("nets: health check requires a name and a check func")
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthTimeout
+	}
+	if check.CacheTTL == 0 {
+		check.CacheTTL = defaultHealthCacheTTL
+	}
+
+	h.mu.Lock()
+	h.checks[check.Name] = &healthEntry{check: check}
+	h.mu.Unlock()
+}
+
+// Unregister 删除健康检查
+func (h *HealthRegistry) Unregister(name string) {
+	h.mu.Lock()
+	delete(h.checks, name)
+	h.mu.Unlock()
+}
+
+// Healthz 返回存活检查handler，只执行Liveness为true的检查
+func (h *HealthRegistry) Healthz() HandlerFunc {
+	return func(c *Context) {
+		report := h.Check(true)
+		c.JSON(healthHTTPStatus(report.Status), Result{Message: report.Status, Data: report})
+	}
+}
+
+// Readyz 返回就绪检查handler，执行全部检查，Server优雅关闭期间直接返回draining
+func (h *HealthRegistry) Readyz() HandlerFunc {
+	return func(c *Context) {
+		var report HealthReport
+		if h.server.Draining() {
+			report = HealthReport{Status: HealthStatusDraining, Checks: map[string]HealthCheckResult{}}
+		} else {
+			report = h.Check(false)
+		}
+		c.JSON(healthHTTPStatus(report.Status), Result{Message: report.Status, Data: report})
+	}
+}
+
+// Check 并发执行检查并汇总结果，liveness为true时只执行Liveness为true的检查
+func (h *HealthRegistry) Check(liveness bool) HealthReport {
+	h.mu.RLock()
+	entries := make([]*healthEntry, 0, len(h.checks))
+	for _, entry := range h.checks {
+		if !liveness || entry.check.Liveness {
+			entries = append(entries, entry)
+		}
+	}
+	h.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].check.Name < entries[j].check.Name })
+
+	results := make([]HealthCheckResult, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry *healthEntry) {
+			defer wg.Done()
+			results[i] = entry.run()
+		}(i, entry)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthStatusOK, Checks: make(map[string]HealthCheckResult, len(entries))}
+	for i, entry := range entries {
+		result := results[i]
+		report.Checks[entry.check.Name] = result
+		if result.Status == HealthStatusOK {
+			continue
+		}
+		if result.Critical {
+			report.Status = HealthStatusFail
+		} else if report.Status == HealthStatusOK {
+			report.Status = HealthStatusDegraded
+		}
+	}
+	return report
+}
+
+// run 执行检查，缓存未过期时返回缓存结果
+// 结果由多个请求共享，因此不使用请求的context，避免客户端断开导致缓存错误结果
+func (entry *healthEntry) run() HealthCheckResult {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(entry.expire) {
+		result := entry.result
+		result.Cached = true
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), entry.check.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fmt.Errorf("nets: health check panic: %v", err)
+			}
+		}()
+		done <- entry.check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrHealthCheckTimeout
+	}
+
+	result := HealthCheckResult{
+		Status:    HealthStatusOK,
+		Critical:  entry.check.Critical,
+		Duration:  time.Since(now),
+		CheckedAt: now,
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+
+	entry.result = result
+	if entry.check.CacheTTL > 0 {
+		entry.expire = now.Add(entry.check.CacheTTL)
+	}
+	return result
+}
+
+// healthHTTPStatus 返回健康状态对应的http status
+func healthHTTPStatus(status string) int {
+	switch status {
+	case HealthStatusOK, HealthStatusDegraded:
+		return http.StatusOK
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
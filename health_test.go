@@ -0,0 +1,245 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// healthServer 返回挂载了/healthz和/readyz的Server
+func healthServer(checks ...HealthCheck) *Server {
+	s := New()
+	for _, check := range checks {
+		s.Health().Register(check)
+	}
+	s.GET("/healthz", s.Health().Healthz())
+	s.GET("/readyz", s.Health().Readyz())
+	s.buildTrees()
+	return s
+}
+
+// healthRequest 请求path并解析响应中的HealthReport
+func healthRequest(t *testing.T, s *Server, path string) (int, HealthReport) {
+	t.Helper()
+	w := do(s, httptest.NewRequest(http.MethodGet, path, nil))
+	var result struct {
+		Message string       `json:"message"`
+		Data    HealthReport `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("GET %s = %d %s: %v", path, w.Code, w.Body.String(), err)
+	}
+	if result.Message != result.Data.Status {
+		t.Errorf("GET %s message %q, status %q", path, result.Message, result.Data.Status)
+	}
+	return w.Code, result.Data
+}
+
+func healthPass(ctx context.Context) error { return nil }
+
+func healthFail(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name          string
+		checks        []HealthCheck
+		healthzStatus int
+		healthz       string
+		readyzStatus  int
+		readyz        string
+	}{
+		{"no checks", nil, http.StatusOK, HealthStatusOK, http.StatusOK, HealthStatusOK},
+		{"all pass", []HealthCheck{
+			{Name: "db", Check: healthPass, Critical: true},
+			{Name: "self", Check: healthPass, Liveness: true},
+		}, http.StatusOK, HealthStatusOK, http.StatusOK, HealthStatusOK},
+		{"critical fail", []HealthCheck{
+			{Name: "db", Check: healthFail, Critical: true},
+			{Name: "self", Check: healthPass, Liveness: true},
+		}, http.StatusOK, HealthStatusOK, http.StatusServiceUnavailable, HealthStatusFail},
+		{"non-critical fail", []HealthCheck{
+			{Name: "cache", Check: healthFail},
+			{Name: "db", Check: healthPass, Critical: true},
+		}, http.StatusOK, HealthStatusOK, http.StatusOK, HealthStatusDegraded},
+		{"critical and non-critical fail", []HealthCheck{
+			{Name: "cache", Check: healthFail},
+			{Name: "db", Check: healthFail, Critical: true},
+		}, http.StatusOK, HealthStatusOK, http.StatusServiceUnavailable, HealthStatusFail},
+		{"liveness fail", []HealthCheck{
+			{Name: "self", Check: healthFail, Critical: true, Liveness: true},
+		}, http.StatusServiceUnavailable, HealthStatusFail, http.StatusServiceUnavailable, HealthStatusFail},
+	}
+	for _, tt := range tests {
+		s := healthServer(tt.checks...)
+
+		code, report := healthRequest(t, s, "/healthz")
+		if code != tt.healthzStatus || report.Status != tt.healthz {
+			t.Errorf("%s: GET /healthz = %d %s, want %d %s", tt.name, code, report.Status, tt.healthzStatus, tt.healthz)
+		}
+		for name := range report.Checks {
+			if name != "self" {
+				t.Errorf("%s: GET /healthz ran readiness check %q", tt.name, name)
+			}
+		}
+
+		code, report = healthRequest(t, s, "/readyz")
+		if code != tt.readyzStatus || report.Status != tt.readyz || len(report.Checks) != len(tt.checks) {
+			t.Errorf("%s: GET /readyz = %d %s %+v, want %d %s", tt.name, code, report.Status, report.Checks, tt.readyzStatus, tt.readyz)
+		}
+		for _, check := range tt.checks {
+			result := report.Checks[check.Name]
+			if result.Critical != check.Critical || (result.Status == HealthStatusFail) != (result.Error != "") {
+				t.Errorf("%s: check %s = %+v", tt.name, check.Name, result)
+			}
+		}
+	}
+}
+
+func TestHealthCheckErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(ctx context.Context) error
+		err   string
+	}{
+		{"error", healthFail, "connection refused"},
+		{"timeout", func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}, ErrHealthCheckTimeout.Error()},
+		{"panic", func(ctx context.Context) error { panic("nil db") }, "nets: health check panic: nil db"},
+	}
+	for _, tt := range tests {
+		h := newHealthRegistry(New())
+		h.Register(HealthCheck{Name: tt.name, Check: tt.check, Timeout: 10 * time.Millisecond, Critical: true})
+		report := h.Check(false)
+		if result := report.Checks[tt.name]; report.Status != HealthStatusFail || result.Error != tt.err {
+			t.Errorf("%s: Check() = %s %+v, want error %q", tt.name, report.Status, result, tt.err)
+		}
+	}
+}
+
+func TestHealthCheckCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		cacheTTL time.Duration
+		calls    int32
+		cached   bool
+	}{
+		{"default ttl", 0, 1, true},
+		{"ttl", time.Hour, 1, true},
+		{"no cache", -1, 3, false},
+	}
+	for _, tt := range tests {
+		var calls int32
+		h := newHealthRegistry(New())
+		h.Register(HealthCheck{Name: "db", CacheTTL: tt.cacheTTL, Check: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return nil
+		}})
+
+		var result HealthCheckResult
+		for i := 0; i < 3; i++ {
+			result = h.Check(false).Checks["db"]
+		}
+		if calls != tt.calls || result.Cached != tt.cached {
+			t.Errorf("%s: calls %d, cached %v, want %d, %v", tt.name, calls, result.Cached, tt.calls, tt.cached)
+		}
+	}
+
+	// 同名注册替换原检查并清除缓存
+	h := newHealthRegistry(New())
+	h.Register(HealthCheck{Name: "db", Check: healthPass, CacheTTL: time.Hour})
+	h.Check(false)
+	h.Register(HealthCheck{Name: "db", Check: healthFail, CacheTTL: time.Hour})
+	if report := h.Check(false); report.Status != HealthStatusDegraded {
+		t.Errorf("Check() after re-register = %s, want %s", report.Status, HealthStatusDegraded)
+	}
+	h.Unregister("db")
+	if report := h.Check(false); report.Status != HealthStatusOK || len(report.Checks) != 0 {
+		t.Errorf("Check() after unregister = %+v, want ok without checks", report)
+	}
+}
+
+func TestHealthRegisterInvalid(t *testing.T) {
+	for _, check := range []HealthCheck{{Check: healthPass}, {Name: "db"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%+v) did not panic", check)
+				}
+			}()
+			newHealthRegistry(New()).Register(check)
+		}()
+	}
+}
+
+func TestReadyzDraining(t *testing.T) {
+	s := healthServer(HealthCheck{Name: "db", Check: healthPass, Critical: true})
+	if code, report := healthRequest(t, s, "/readyz"); code != http.StatusOK || report.Status != HealthStatusOK {
+		t.Fatalf("GET /readyz = %d %s, want 200 ok", code, report.Status)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if !s.Draining() {
+		t.Fatal("Draining() = false after Shutdown")
+	}
+	code, report := healthRequest(t, s, "/readyz")
+	if code != http.StatusServiceUnavailable || report.Status != HealthStatusDraining || len(report.Checks) != 0 {
+		t.Errorf("GET /readyz while draining = %d %+v, want 503 draining", code, report)
+	}
+	// 存活检查不受draining影响
+	if code, report := healthRequest(t, s, "/healthz"); code != http.StatusOK || report.Status != HealthStatusOK {
+		t.Errorf("GET /healthz while draining = %d %s, want 200 ok", code, report.Status)
+	}
+
+	// 重新启动时清除draining
+	s.newHTTPServer(":0")
+	if s.Draining() {
+		t.Error("Draining() = true after restart")
+	}
+	if code, report := healthRequest(t, s, "/readyz"); code != http.StatusOK || report.Status != HealthStatusOK {
+		t.Errorf("GET /readyz after restart = %d %s, want 200 ok", code, report.Status)
+	}
+}
+
+func TestShutdownDelay(t *testing.T) {
+	s := healthServer()
+	s.Config.SetShutdownDelay(time.Hour)
+
+	done := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- s.Shutdown(ctx) }()
+
+	// 等待期间就绪检查返回draining
+	for deadline := time.Now().Add(time.Second); !s.Draining() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if code, report := healthRequest(t, s, "/readyz"); code != http.StatusServiceUnavailable || report.Status != HealthStatusDraining {
+		t.Errorf("GET /readyz during shutdown delay = %d %s, want 503 draining", code, report.Status)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown() returned %v before ctx was done", err)
+	default:
+	}
+
+	// ctx结束时缩短等待
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown() did not return after ctx was done")
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogFormat 访问日志格式
+type LogFormat int
+
+const (
+	// LogFormatText 文本格式
+	LogFormatText LogFormat = iota
+	// LogFormatJSON json格式
+	LogFormatJSON
+	// LogFormatLogfmt logfmt格式
+	LogFormatLogfmt
+)
+
+const (
+	// 默认的请求id请求头
+	defaultRequestIDHeader string = "X-Request-Id"
+
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[97;42m"
+	colorWhite  = "\033[90;47m"
+	colorYellow = "\033[90;43m"
+	colorRed    = "\033[97;41m"
+	colorBlue   = "\033[97;44m"
+	colorCyan   = "\033[97;46m"
+)
+
+// LoggerConfig 访问日志配置
+type LoggerConfig struct {
+	// 日志输出，为nil时使用os.Stdout
+	Output io.Writer
+	// 日志格式，Handler不为nil时忽略
+	Format LogFormat
+	// slog handler，不为nil时使用slog输出，忽略Output和Format
+	Handler slog.Handler
+	// 不记录日志的请求路径
+	SkipPaths []string
+	// 请求id请求头，为空时使用X-Request-Id
+	RequestIDHeader string
+	// 是否禁用颜色，文本格式在development环境下默认输出颜色
+	DisableColor bool
+}
+
+// AccessLog 一条访问日志
+type AccessLog struct {
+	Time      time.Time       `json:"time"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Route     string          `json:"route,omitempty"`
+	Status    int             `json:"status"`
+	Latency   time.Duration   `json:"latency"`
+	Size      int             `json:"size"`
+	ClientIP  string          `json:"client_ip"`
+	RequestID string          `json:"request_id,omitempty"`
+	UserAgent string          `json:"user_agent"`
+	Errors    []string        `json:"errors,omitempty"`
+	Timings   []HandlerTiming `json:"timings,omitempty"`
+}
+
+// Logger 返回访问日志trace handler，使用Server.Trace注册：s.Trace(nets.Logger())
+func Logger(config ...LoggerConfig) HandlerFunc {
+	cfg := LoggerConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stdout
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = defaultRequestIDHeader
+	}
+
+	skips := make(map[string]bool, len(cfg.SkipPaths))
+	for _, path := range cfg.SkipPaths {
+		skips[path] = true
+	}
+
+	var logger *slog.Logger
+	if cfg.Handler != nil {
+		logger = slog.New(cfg.Handler)
+	}
+
+	var mu sync.Mutex
+	return func(c *Context) {
+		if skips[c.Request.URL.Path] {
+			return
+		}
+
+		entry := newAccessLog(c, cfg.RequestIDHeader)
+		if logger != nil {
+			logger.LogAttrs(c.Request.Context(), accessLogLevel(entry.Status), "request", entry.attrs()...)
+			return
+		}
+
+		var line []byte
+		switch cfg.Format {
+		case LogFormatJSON:
+			data, err := c.server.json.Marshal(entry)
+			if err != nil {
+				debugPrintf("[ERROR] cannot marshal access log: %v\n", err)
+				return
+			}
+			line = append(data, '\n')
+		case LogFormatLogfmt:
+			line = entry.logfmt()
+		default:
+			color := !cfg.DisableColor && c.server.Config.env == EnvDevelopment
+			line = entry.text(color)
+		}
+
+		mu.Lock()
+		cfg.Output.Write(line)
+		mu.Unlock()
+	}
+}
+
+// newAccessLog 根据Context和trace构造访问日志
+func newAccessLog(c *Context, requestIDHeader string) AccessLog {
+	entry := AccessLog{
+		Time:      c.Trace.StartTime,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Route:     c.FullPath(),
+		Status:    c.Trace.Status,
+		Latency:   c.Trace.EndTime.Sub(c.Trace.StartTime),
+		Size:      c.responser.Size(),
+		ClientIP:  c.Trace.ClientIP,
+		RequestID: c.Request.Header.Get(requestIDHeader),
+		UserAgent: c.Request.UserAgent(),
+		Errors:    c.Trace.Errors,
+		Timings:   c.Trace.Timings,
+	}
+	if entry.Status == 0 {
+		entry.Status = c.responser.Status()
+	}
+	if entry.Size < 0 {
+		entry.Size = 0
+	}
+	return entry
+}
+
+// attrs 返回slog属性
+func (entry AccessLog) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", entry.Method),
+		slog.String("path", entry.Path),
+		slog.String("route", entry.Route),
+		slog.Int("status", entry.Status),
+		slog.Duration("latency", entry.Latency),
+		slog.Int("size", entry.Size),
+		slog.String("client_ip", entry.ClientIP),
+		slog.String("user_agent", entry.UserAgent),
+	}
+	if entry.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", entry.RequestID))
+	}
+	if len(entry.Errors) > 0 {
+		attrs = append(attrs, slog.Any("errors", entry.Errors))
+	}
+	if len(entry.Timings) > 0 {
+		timings := make([]interface{}, 0, len(entry.Timings))
+		for _, timing := range entry.Timings {
+			timings = append(timings, slog.Duration(timing.Name, timing.Self))
+		}
+		attrs = append(attrs, slog.Group("timings", timings...))
+	}
+	return attrs
+}
+
+// text 返回文本格式日志
+func (entry AccessLog) text(color bool) []byte {
+	status, method := strconv.Itoa(entry.Status), entry.Method
+	if color {
+		status = statusColor(entry.Status) + " " + status + " " + colorReset
+		method = methodColor(entry.Method) + " " + method + " " + colorReset
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "[NETS] %s | %s | %13v | %15s | %s %q | %d | %s | %q",
+		entry.Time.Format("2006/01/02 - 15:04:05"),
+		status,
+		entry.Latency,
+		entry.ClientIP,
+		method,
+		entry.Path,
+		entry.Size,
+		entry.RequestID,
+		entry.UserAgent,
+	)
+	if len(entry.Errors) > 0 {
+		fmt.Fprintf(buf, " | %s", strings.Join(entry.Errors, "; "))
+	}
+	if len(entry.Timings) > 0 {
+		fmt.Fprintf(buf, " | %s", entry.timings())
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// timings 返回各handler自身耗时，如 "main.auth=1.2ms main.getUser=3ms"
+func (entry AccessLog) timings() string {
+	items := make([]string, 0, len(entry.Timings))
+	for _, timing := range entry.Timings {
+		items = append(items, timing.Name+"="+timing.Self.String())
+	}
+	return strings.Join(items, " ")
+}
+
+// logfmtPair logfmt键值对
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+// logfmt 返回logfmt格式日志
+func (entry AccessLog) logfmt() []byte {
+	buf := new(bytes.Buffer)
+	pairs := []logfmtPair{
+		{"time", entry.Time.Format(time.RFC3339Nano)},
+		{"method", entry.Method},
+		{"path", entry.Path},
+		{"route", entry.Route},
+		{"status", strconv.Itoa(entry.Status)},
+		{"latency", entry.Latency.String()},
+		{"size", strconv.Itoa(entry.Size)},
+		{"client_ip", entry.ClientIP},
+		{"request_id", entry.RequestID},
+		{"user_agent", entry.UserAgent},
+	}
+	if len(entry.Errors) > 0 {
+		pairs = append(pairs, logfmtPair{"errors", strings.Join(entry.Errors, "; ")})
+	}
+	if len(entry.Timings) > 0 {
+		pairs = append(pairs, logfmtPair{"timings", entry.timings()})
+	}
+
+	for i, pair := range pairs {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(pair.key)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(pair.value))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// logfmtValue 值包含空白、引号或等号时加引号
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// accessLogLevel 按http status返回日志级别
+func accessLogLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// statusColor 返回http status对应的颜色
+func statusColor(status int) string {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return colorRed
+	case status >= http.StatusBadRequest:
+		return colorYellow
+	case status >= http.StatusMultipleChoices:
+		return colorWhite
+	default:
+		return colorGreen
+	}
+}
+
+// methodColor 返回http method对应的颜色
+func methodColor(method string) string {
+	switch method {
+	case http.MethodGet:
+		return colorBlue
+	case http.MethodPost:
+		return colorCyan
+	case http.MethodPut, http.MethodPatch:
+		return colorYellow
+	case http.MethodDelete:
+		return colorRed
+	default:
+		return colorWhite
+	}
+}
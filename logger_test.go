@@ -0,0 +1,151 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// loggerServer 返回使用Logger的Server，/users/:id 响应status并记录错误
+func loggerServer(env string, config LoggerConfig) *Server {
+	s := New()
+	s.Config.SetEnv(env)
+	s.Trace(Logger(config))
+	s.GET("/users/:id", func(c *Context) {
+		c.Error(errors.New("boom"))
+		c.JSON(http.StatusInternalServerError, Result{})
+	})
+	s.GET("/health", func(c *Context) {})
+	s.buildTrees()
+	return s
+}
+
+// logRequest 发起带User-Agent和X-Request-Id的请求
+func logRequest(s *Server, path string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("User-Agent", "nets-test")
+	req.Header.Set("X-Request-Id", "rid-1")
+	do(s, req)
+}
+
+func TestLoggerFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		format   LogFormat
+		color    bool
+		contains []string
+	}{
+		{"text", EnvTest, LogFormatText, false,
+			[]string{"[NETS] ", "| 500 |", `| GET "/users/1" |`, "| rid-1 |", `"nets-test"`, "| boom"}},
+		{"text color", EnvDevelopment, LogFormatText, true,
+			[]string{colorRed + " 500 " + colorReset, colorBlue + " GET " + colorReset}},
+		{"logfmt", EnvDevelopment, LogFormatLogfmt, false,
+			[]string{"method=GET path=/users/1 ", " status=500 ", " request_id=rid-1 ", " user_agent=nets-test", " errors=boom"}},
+	}
+	for _, tt := range tests {
+		output := new(bytes.Buffer)
+		logRequest(loggerServer(tt.env, LoggerConfig{Output: output, Format: tt.format}), "/users/1")
+
+		line := output.String()
+		if strings.Count(line, "\n") != 1 || strings.Contains(line, "\033[") != tt.color {
+			t.Errorf("%s: log line %q", tt.name, line)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(line, s) {
+				t.Errorf("%s: log line %q does not contain %q", tt.name, line, s)
+			}
+		}
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	output := new(bytes.Buffer)
+	logRequest(loggerServer(EnvTest, LoggerConfig{Output: output, Format: LogFormatJSON, RequestIDHeader: "X-Request-Id"}), "/users/1")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		t.Fatalf("access log %q is not json: %v", output.String(), err)
+	}
+	want := map[string]interface{}{
+		"method":     "GET",
+		"path":       "/users/1",
+		"status":     float64(500),
+		"client_ip":  "192.0.2.1",
+		"request_id": "rid-1",
+		"user_agent": "nets-test",
+		"errors":     []interface{}{"boom"},
+	}
+	for key, value := range want {
+		got, _ := json.Marshal(entry[key])
+		if expected, _ := json.Marshal(value); string(got) != string(expected) {
+			t.Errorf("access log %s = %s, want %s", key, got, expected)
+		}
+	}
+	if size, _ := entry["size"].(float64); size <= 0 {
+		t.Errorf("access log size = %v", entry["size"])
+	}
+}
+
+func TestLoggerSlog(t *testing.T) {
+	tests := []struct {
+		path  string
+		level string
+	}{
+		{"/users/1", "ERROR"},
+		{"/none", "WARN"},
+	}
+	for _, tt := range tests {
+		output := new(bytes.Buffer)
+		logRequest(loggerServer(EnvTest, LoggerConfig{Handler: slog.NewJSONHandler(output, nil)}), tt.path)
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+			t.Fatalf("slog output %q is not json: %v", output.String(), err)
+		}
+		if entry["level"] != tt.level || entry["msg"] != "request" || entry["path"] != tt.path || entry["request_id"] != "rid-1" {
+			t.Errorf("GET %s: slog entry %v", tt.path, entry)
+		}
+	}
+}
+
+func TestLoggerSkipPaths(t *testing.T) {
+	output := new(bytes.Buffer)
+	s := loggerServer(EnvTest, LoggerConfig{Output: output, SkipPaths: []string{"/health"}})
+	logRequest(s, "/health")
+	if output.Len() != 0 {
+		t.Errorf("skipped path logged: %q", output.String())
+	}
+	logRequest(s, "/users/1")
+	if output.Len() == 0 {
+		t.Error("request not logged")
+	}
+}
+
+func TestLogfmtValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"a b", `"a b"`},
+		{"a=b", `"a=b"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"line\nbreak", `"line\nbreak"`},
+	}
+	for _, tt := range tests {
+		if got := logfmtValue(tt.value); got != tt.want {
+			t.Errorf("logfmtValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
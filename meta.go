@@ -10,6 +10,7 @@ const (
 
 type priorityHandlers struct {
 	handlers HandlerChain
+	names    []string
 	priority int
 }
 
@@ -18,6 +19,7 @@ type meta struct {
 	patternLen         int
 	priorityHandlerses []priorityHandlers
 	handlers           HandlerChain
+	handlerNames       []string
 	paramKeys          []string
 	index              int
 }
@@ -70,16 +72,17 @@ type methodMetas []methodMeta
 // 同一次注册的多个中间件优先级相同
 // 对于中间件，相同的pattern，handlers会叠加
 // 对于路由，相同的pattern，新handlers会替换旧的handlers
-func (m *methodMetas) add(method, pattern string, priority int, paramKeys []string, handlers HandlerChain) {
+func (m *methodMetas) add(method, pattern string, priority int, paramKeys []string, handlers HandlerChain, names []string) {
 	mMeta := m.get(method)
 
 	isMiddleware := method == methodMiddleware
 	meta := meta{pattern: pattern, patternLen: len(pattern), paramKeys: paramKeys}
 	if isMiddleware {
-		p := priorityHandlers{priority: priority, handlers: handlers}
+		p := priorityHandlers{priority: priority, handlers: handlers, names: names}
 		meta.priorityHandlerses = []priorityHandlers{p}
 	} else {
 		meta.handlers = handlers
+		meta.handlerNames = names
 	}
 
 	if length := len(mMeta.metas); length > 0 {
@@ -88,6 +91,7 @@ func (m *methodMetas) add(method, pattern string, priority int, paramKeys []stri
 				mMeta.metas[index].priorityHandlerses = append(mMeta.metas[index].priorityHandlerses, meta.priorityHandlerses...)
 			} else {
 				mMeta.metas[index].handlers = meta.handlers
+				mMeta.metas[index].handlerNames = meta.handlerNames
 			}
 		} else {
 			meta.index = length
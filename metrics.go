@@ -0,0 +1,322 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ContentTypePrometheus Prometheus text exposition format
+	ContentTypePrometheus string = "text/plain; version=0.0.4; charset=utf-8"
+
+	// 默认指标名前缀
+	defaultMetricsNamespace string = "nets"
+)
+
+var (
+	// 默认耗时分桶（秒）
+	defaultMetricsDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// 默认响应大小分桶（字节）
+	defaultMetricsSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+// MetricsConfig 指标配置
+type MetricsConfig struct {
+	// 指标名前缀，默认nets
+	Namespace string
+	// 请求耗时分桶（秒），默认.005 ~ 10
+	DurationBuckets []float64
+	// 响应大小分桶（字节），默认100 ~ 10M
+	SizeBuckets []float64
+}
+
+// Metrics 按method、路由和http status统计请求数、耗时和响应大小（RED指标），
+// 以Prometheus text格式输出，不依赖Prometheus客户端库
+//
+//	metrics := nets.NewMetrics()
+//	s.Use(metrics.Middleware())
+//	s.NoRoute(metrics.Middleware())
+//	s.GET("/metrics", metrics.Expose())
+type Metrics struct {
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+	startTime       time.Time
+	inFlight        int64
+
+	mu     sync.RWMutex
+	series map[metricsLabels]*metricsSeries
+}
+
+// metricsLabels 指标标签
+type metricsLabels struct {
+	method string
+	route  string
+	status string
+}
+
+// metricsSeries 一组标签对应的请求数、耗时和响应大小
+type metricsSeries struct {
+	mu       sync.Mutex
+	count    uint64
+	duration histogram
+	size     histogram
+}
+
+// histogram 累积分桶直方图
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// NewMetrics 返回新的Metrics
+func NewMetrics(config ...MetricsConfig) *Metrics {
+	cfg := MetricsConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultMetricsNamespace
+	}
+	if len(cfg.DurationBuckets) == 0 {
+		cfg.DurationBuckets = defaultMetricsDurationBuckets
+	}
+	if len(cfg.SizeBuckets) == 0 {
+		cfg.SizeBuckets = defaultMetricsSizeBuckets
+	}
+
+	return &Metrics{
+		namespace:       cfg.Namespace,
+		durationBuckets: sortedBuckets(cfg.DurationBuckets),
+		sizeBuckets:     sortedBuckets(cfg.SizeBuckets),
+		startTime:       time.Now(),
+		series:          make(map[metricsLabels]*metricsSeries),
+	}
+}
+
+// Middleware 返回统计请求的中间件，route标签为注册的路由（如/users/:id）而非原始路径
+// 后续handler panic时按500记录；路由未命中的请求需通过Server.NoRoute注册该中间件才会统计，route标签为空
+func (m *Metrics) Middleware() HandlerFunc {
+	return func(c *Context) {
+		atomic.AddInt64(&m.inFlight, 1)
+		start := time.Now()
+		defer func() {
+			atomic.AddInt64(&m.inFlight, -1)
+			if err := recover(); err != nil {
+				// 响应尚未由recovery中间件写出，按500记录后继续panic
+				m.observe(c, time.Since(start), http.StatusInternalServerError)
+				panic(err)
+			}
+			m.observe(c, time.Since(start), c.responser.Status())
+		}()
+		c.Next()
+	}
+}
+
+// Expose 返回输出Prometheus text格式指标的handler
+func (m *Metrics) Expose() HandlerFunc {
+	return func(c *Context) {
+		if !c.writable() {
+			return
+		}
+		c.responser.Header().Set("Content-Type", ContentTypePrometheus)
+		c.responser.WriteHeader(http.StatusOK)
+		if _, err := c.responser.Write(m.text(c.server)); err != nil && !isBrokenPipe(err) {
+			debugPrintf("[ERROR] cannot write metrics: %v\n", err)
+		}
+	}
+}
+
+// observe 记录一次请求
+func (m *Metrics) observe(c *Context, duration time.Duration, status int) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	size := c.responser.Size()
+	if size < 0 {
+		size = 0
+	}
+
+	labels := metricsLabels{method: c.Request.Method, route: c.fullPath, status: strconv.Itoa(status)}
+	m.mu.RLock()
+	series, ok := m.series[labels]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		if series, ok = m.series[labels]; !ok {
+			series = &metricsSeries{
+				duration: histogram{counts: make([]uint64, len(m.durationBuckets))},
+				size:     histogram{counts: make([]uint64, len(m.sizeBuckets))},
+			}
+			m.series[labels] = series
+		}
+		m.mu.Unlock()
+	}
+
+	series.mu.Lock()
+	series.count++
+	series.duration.observe(m.durationBuckets, duration.Seconds())
+	series.size.observe(m.sizeBuckets, float64(size))
+	series.mu.Unlock()
+}
+
+// observe 记录一个值
+func (h *histogram) observe(buckets []float64, value float64) {
+	h.sum += value
+	for i, bound := range buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// text 返回Prometheus text格式指标
+func (m *Metrics) text(s *Server) []byte {
+	m.mu.RLock()
+	labels := make([]metricsLabels, 0, len(m.series))
+	for k := range m.series {
+		labels = append(labels, k)
+	}
+	m.mu.RUnlock()
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].route != labels[j].route {
+			return labels[i].route < labels[j].route
+		}
+		if labels[i].method != labels[j].method {
+			return labels[i].method < labels[j].method
+		}
+		return labels[i].status < labels[j].status
+	})
+
+	type snapshot struct {
+		labels   metricsLabels
+		count    uint64
+		duration histogram
+		size     histogram
+	}
+	snapshots := make([]snapshot, 0, len(labels))
+	for _, k := range labels {
+		m.mu.RLock()
+		series := m.series[k]
+		m.mu.RUnlock()
+
+		series.mu.Lock()
+		snapshots = append(snapshots, snapshot{
+			labels:   k,
+			count:    series.count,
+			duration: histogram{counts: append([]uint64(nil), series.duration.counts...), sum: series.duration.sum},
+			size:     histogram{counts: append([]uint64(nil), series.size.counts...), sum: series.size.sum},
+		})
+		series.mu.Unlock()
+	}
+
+	buf := new(bytes.Buffer)
+
+	name := m.namespace + "_http_requests_total"
+	writeMetricHeader(buf, name, "counter", "Total number of HTTP requests.")
+	for _, v := range snapshots {
+		fmt.Fprintf(buf, "%s{%s} %d\n", name, v.labels.text(), v.count)
+	}
+
+	name = m.namespace + "_http_request_duration_seconds"
+	writeMetricHeader(buf, name, "histogram", "HTTP request latency in seconds.")
+	for _, v := range snapshots {
+		writeHistogram(buf, name, v.labels.text(), m.durationBuckets, v.duration, v.count)
+	}
+
+	name = m.namespace + "_http_response_size_bytes"
+	writeMetricHeader(buf, name, "histogram", "HTTP response size in bytes.")
+	for _, v := range snapshots {
+		writeHistogram(buf, name, v.labels.text(), m.sizeBuckets, v.size, v.count)
+	}
+
+	name = m.namespace + "_http_requests_in_flight"
+	writeMetricHeader(buf, name, "gauge", "Number of HTTP requests currently being served.")
+	fmt.Fprintf(buf, "%s %d\n", name, atomic.LoadInt64(&m.inFlight))
+
+	name = m.namespace + "_context_pool_allocated_total"
+	writeMetricHeader(buf, name, "counter", "Number of contexts allocated by the context pool.")
+	fmt.Fprintf(buf, "%s %d\n", name, atomic.LoadUint64(&s.contexts))
+
+	if len(s.tracePipelines) > 0 {
+		stats := s.TraceStats()
+		name = m.namespace + "_trace_records_total"
+		writeMetricHeader(buf, name, "counter", "Number of trace records by async trace pipeline result.")
+		fmt.Fprintf(buf, "%s{result=\"queued\"} %d\n", name, stats.Queued)
+		fmt.Fprintf(buf, "%s{result=\"processed\"} %d\n", name, stats.Processed)
+		fmt.Fprintf(buf, "%s{result=\"failed\"} %d\n", name, stats.Failed)
+		fmt.Fprintf(buf, "%s{result=\"dropped\"} %d\n", name, stats.Dropped)
+
+		name = m.namespace + "_trace_queue_length"
+		writeMetricHeader(buf, name, "gauge", "Number of trace records waiting in the async trace queue.")
+		fmt.Fprintf(buf, "%s %d\n", name, stats.QueueLen)
+	}
+
+	name = m.namespace + "_goroutines"
+	writeMetricHeader(buf, name, "gauge", "Number of goroutines.")
+	fmt.Fprintf(buf, "%s %d\n", name, runtime.NumGoroutine())
+
+	name = m.namespace + "_uptime_seconds"
+	writeMetricHeader(buf, name, "gauge", "Seconds since the metrics were created.")
+	fmt.Fprintf(buf, "%s %s\n", name, formatMetricValue(time.Since(m.startTime).Seconds()))
+
+	return buf.Bytes()
+}
+
+// text 返回标签文本
+func (labels metricsLabels) text() string {
+	return `method="` + escapeLabelValue(labels.method) +
+		`",route="` + escapeLabelValue(labels.route) +
+		`",status="` + escapeLabelValue(labels.status) + `"`
+}
+
+// writeMetricHeader 输出HELP和TYPE
+func writeMetricHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeHistogram 输出直方图的bucket、sum和count
+func writeHistogram(buf *bytes.Buffer, name, labels string, buckets []float64, h histogram, count uint64) {
+	for i, bound := range buckets {
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatMetricValue(bound), h.counts[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, count)
+	fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatMetricValue(h.sum))
+	fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, count)
+}
+
+// formatMetricValue 格式化指标值
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabelValue 转义标签值中的反斜杠、双引号和换行符
+func escapeLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// sortedBuckets 返回升序排列的分桶副本
+func sortedBuckets(buckets []float64) []float64 {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return sorted
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// metricsServer 返回统计请求的Server：/ok 正常响应，/panic panic，/metrics 输出指标
+func metricsServer(m *Metrics) *Server {
+	s := New()
+	s.Use(m.Middleware())
+	s.NoRoute(m.Middleware())
+	s.GET("/ok", func(c *Context) { c.JSON(http.StatusOK, Result{}) })
+	s.GET("/panic", func(c *Context) { panic("boom") })
+	s.GET("/metrics", m.Expose())
+	s.buildTrees()
+	return s
+}
+
+func TestMetricsExposition(t *testing.T) {
+	s := metricsServer(NewMetrics())
+	for _, path := range []string{"/ok", "/ok", "/panic", "/none"} {
+		serve(s, http.MethodGet, path)
+	}
+	w := do(s, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != ContentTypePrometheus {
+		t.Fatalf("GET /metrics = %d, Content-Type %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	ok := `method="GET",route="/ok",status="200"`
+	tests := []string{
+		"# HELP nets_http_requests_total Total number of HTTP requests.\n# TYPE nets_http_requests_total counter\n",
+		"nets_http_requests_total{" + ok + "} 2\n",
+		"# TYPE nets_http_request_duration_seconds histogram\n",
+		"nets_http_request_duration_seconds_bucket{" + ok + `,le="+Inf"} 2` + "\n",
+		"nets_http_request_duration_seconds_count{" + ok + "} 2\n",
+		"nets_http_response_size_bytes_bucket{" + ok + `,le="100"} 2` + "\n",
+		"nets_http_response_size_bytes_sum{" + ok + "} 66\n",
+		"nets_http_requests_in_flight 1\n",
+		"# TYPE nets_context_pool_allocated_total counter\n",
+		"# TYPE nets_goroutines gauge\n",
+		"# TYPE nets_uptime_seconds gauge\n",
+	}
+	body := w.Body.String()
+	for _, want := range tests {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+	// handler panic按500记录，路由未命中的请求通过NoRoute记录为404
+	for _, pattern := range []string{
+		`(?m)^nets_http_requests_total\{method="GET",route="[^"]*",status="500"\} 1$`,
+		`(?m)^nets_http_requests_total\{method="GET",route="[^"]*",status="404"\} 1$`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(body) {
+			t.Errorf("metrics do not match %s", pattern)
+		}
+	}
+	if strings.Contains(body, "nets_trace_records_total") {
+		t.Error("trace metrics exposed without async trace pipeline")
+	}
+}
+
+func TestMetricsConfig(t *testing.T) {
+	s := metricsServer(NewMetrics(MetricsConfig{Namespace: "app", DurationBuckets: []float64{1, 0.1}, SizeBuckets: []float64{10}}))
+	s.TraceAsync(&recordSink{})
+	serve(s, http.MethodGet, "/ok")
+	body := do(s, httptest.NewRequest(http.MethodGet, "/metrics", nil)).Body.String()
+
+	ok := `method="GET",route="/ok",status="200"`
+	want := "app_http_request_duration_seconds_bucket{" + ok + `,le="0.1"} 1` + "\n" +
+		"app_http_request_duration_seconds_bucket{" + ok + `,le="1"} 1` + "\n" +
+		"app_http_request_duration_seconds_bucket{" + ok + `,le="+Inf"} 1` + "\n"
+	if !strings.Contains(body, want) {
+		t.Errorf("metrics do not contain sorted custom buckets:\n%s", body)
+	}
+	if !strings.Contains(body, "app_http_response_size_bytes_bucket{"+ok+`,le="10"} 0`+"\n") {
+		t.Errorf("metrics do not contain custom size bucket")
+	}
+	if !strings.Contains(body, `app_trace_records_total{result="queued"}`) || !strings.Contains(body, "app_trace_queue_length ") {
+		t.Errorf("metrics do not contain trace pipeline metrics")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	buckets := []float64{1, 2, 3}
+	h := histogram{counts: make([]uint64, len(buckets))}
+	for _, value := range []float64{0.5, 2, 5} {
+		h.observe(buckets, value)
+	}
+	if got := []uint64{h.counts[0], h.counts[1], h.counts[2]}; got[0] != 1 || got[1] != 2 || got[2] != 2 || h.sum != 7.5 {
+		t.Errorf("histogram counts %v, sum %v, want [1 2 2], 7.5", got, h.sum)
+	}
+}
+
+func TestMetricsFormatting(t *testing.T) {
+	values := []struct {
+		value float64
+		want  string
+	}{
+		{0.005, "0.005"},
+		{10, "10"},
+		{1e7, "1e+07"},
+		{math.Inf(1), "+Inf"},
+	}
+	for _, tt := range values {
+		if got := formatMetricValue(tt.value); got != tt.want {
+			t.Errorf("formatMetricValue(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	labels := []struct {
+		value string
+		want  string
+	}{
+		{"/users/:id", "/users/:id"},
+		{`a\b`, `a\\b`},
+		{`say "hi"`, `say \"hi\"`},
+		{"a\nb", `a\nb`},
+	}
+	for _, tt := range labels {
+		if got := escapeLabelValue(tt.value); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
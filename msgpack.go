@@ -0,0 +1,363 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// marshalMsgPack 将v序列化为msgpack格式数据
+// 结构体字段名优先使用msgpack tag，其次使用json tag
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := encodeMsgPack(buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeMsgPack 按值类型写入msgpack编码
+func encodeMsgPack(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+
+	var value interface{}
+	if v.CanInterface() {
+		value = v.Interface()
+	}
+	switch value := value.(type) {
+	case time.Time:
+		writeMsgPackString(buf, value.Format(time.RFC3339Nano))
+		return nil
+	case []byte:
+		writeMsgPackBytes(buf, value)
+		return nil
+	case encoding.TextMarshaler:
+		if v.Kind() != reflect.Ptr || !v.IsNil() {
+			text, err := value.MarshalText()
+			if err != nil {
+				return err
+			}
+			writeMsgPackString(buf, string(text))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return encodeMsgPack(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeMsgPackInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeMsgPackUint(buf, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		writeMsgPackString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		length := v.Len()
+		writeMsgPackHeader(buf, length, 0x90, 0xdc, 0xdd)
+		for i := 0; i < length; i++ {
+			if err := encodeMsgPack(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		writeMsgPackHeader(buf, len(keys), 0x80, 0xde, 0xdf)
+		for _, key := range keys {
+			if err := encodeMsgPack(buf, key); err != nil {
+				return err
+			}
+			if err := encodeMsgPack(buf, v.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return encodeMsgPackStruct(buf, v)
+	default:
+		return fmt.Errorf("msgpack: unsupported type: %s", v.Type())
+	}
+
+	return nil
+}
+
+// msgPackFieldsCache 结构体类型对应的编码字段
+var msgPackFieldsCache sync.Map
+
+// msgPackField 结构体中参与编码的字段
+type msgPackField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitempty bool
+}
+
+// encodeMsgPackStruct 将结构体编码为msgpack map，匿名嵌入结构体的字段与encoding/json一样被提升到外层
+func encodeMsgPackStruct(buf *bytes.Buffer, v reflect.Value) error {
+	fields := msgPackFields(v.Type())
+	names, values := make([]string, 0, len(fields)), make([]reflect.Value, 0, len(fields))
+	for _, field := range fields {
+		fv, ok := msgPackFieldByIndex(v, field.index)
+		if !ok || (field.omitempty && fv.IsZero()) {
+			continue
+		}
+		names = append(names, field.name)
+		values = append(values, fv)
+	}
+
+	writeMsgPackHeader(buf, len(names), 0x80, 0xde, 0xdf)
+	for i, name := range names {
+		writeMsgPackString(buf, name)
+		if err := encodeMsgPack(buf, values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// msgPackFields 返回结构体t参与编码的字段（含匿名嵌入结构体中被提升的字段），结果按类型缓存
+// 同名字段按encoding/json的规则处理：层级浅的优先，同层级时有tag的优先，仍无法区分时全部忽略
+func msgPackFields(t reflect.Type) []msgPackField {
+	if fields, ok := msgPackFieldsCache.Load(t); ok {
+		return fields.([]msgPackField)
+	}
+
+	all := []msgPackField{}
+	collectMsgPackFields(t, nil, map[reflect.Type]bool{}, &all)
+
+	positions := map[string][]int{}
+	for i, field := range all {
+		positions[field.name] = append(positions[field.name], i)
+	}
+
+	fields := make([]msgPackField, 0, len(all))
+	for i, field := range all {
+		if dominantMsgPackField(all, positions[field.name]) == i {
+			fields = append(fields, field)
+		}
+	}
+	msgPackFieldsCache.Store(t, fields)
+	return fields
+}
+
+// collectMsgPackFields 按声明顺序收集t的字段，index为t在外层结构体中的字段路径
+func collectMsgPackFields(t reflect.Type, index []int, visited map[reflect.Type]bool, fields *[]msgPackField) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		ft := field.Type
+		if field.Anonymous && ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous {
+			if field.PkgPath != "" && ft.Kind() != reflect.Struct {
+				continue
+			}
+		} else if field.PkgPath != "" {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("msgpack")
+		if !ok {
+			tag = field.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+
+		tags := strings.Split(tag, ",")
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if tags[0] == "" && field.Anonymous && ft.Kind() == reflect.Struct {
+			collectMsgPackFields(ft, fieldIndex, visited, fields)
+			continue
+		}
+
+		f := msgPackField{name: field.Name, index: fieldIndex}
+		if tags[0] != "" {
+			f.name, f.tagged = tags[0], true
+		}
+		for _, opt := range tags[1:] {
+			f.omitempty = f.omitempty || opt == "omitempty"
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// dominantMsgPackField 返回同名字段（positions为其在fields中的下标）中生效字段的下标，没有时返回-1
+func dominantMsgPackField(fields []msgPackField, positions []int) int {
+	depth := len(fields[positions[0]].index)
+	for _, i := range positions[1:] {
+		if len(fields[i].index) < depth {
+			depth = len(fields[i].index)
+		}
+	}
+
+	dominant, candidates, tagged := -1, 0, 0
+	for _, i := range positions {
+		if len(fields[i].index) != depth {
+			continue
+		}
+		candidates++
+		if fields[i].tagged {
+			tagged++
+			dominant = i
+		} else if tagged == 0 {
+			dominant = i
+		}
+	}
+	if candidates == 1 || tagged == 1 {
+		return dominant
+	}
+	return -1
+}
+
+// msgPackFieldByIndex 按字段路径取值，路径上的嵌入指针为nil时返回false
+func msgPackFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// writeMsgPackHeader 写入array/map的长度头
+func writeMsgPackHeader(buf *bytes.Buffer, length int, fix, code16, code32 byte) {
+	switch {
+	case length < 16:
+		buf.WriteByte(fix | byte(length))
+	case length <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+}
+
+// writeMsgPackInt 写入有符号整数
+func writeMsgPackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		writeMsgPackUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// writeMsgPackUint 写入无符号整数
+func writeMsgPackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// writeMsgPackString 写入字符串
+func writeMsgPackString(buf *bytes.Buffer, s string) {
+	length := len(s)
+	switch {
+	case length < 32:
+		buf.WriteByte(0xa0 | byte(length))
+	case length <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(length))
+	case length <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+	buf.WriteString(s)
+}
+
+// writeMsgPackBytes 写入二进制数据
+func writeMsgPackBytes(buf *bytes.Buffer, data []byte) {
+	length := len(data)
+	switch {
+	case length <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(length))
+	case length <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+	buf.Write(data)
+}
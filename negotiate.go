@@ -0,0 +1,154 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MIMEJSON json MIME
+	MIMEJSON string = "application/json"
+	// MIMEXML xml MIME
+	MIMEXML string = "application/xml"
+	// MIMEXML2 xml MIME
+	MIMEXML2 string = "text/xml"
+	// MIMEMsgPack msgpack MIME
+	MIMEMsgPack string = "application/x-msgpack"
+	// MIMEMsgPack2 msgpack MIME
+	MIMEMsgPack2 string = "application/msgpack"
+)
+
+// defaultOffers Negotiate未指定offers时可供协商的MIME
+var defaultOffers = []string{MIMEJSON, MIMEXML, MIMEXML2, MIMEMsgPack, MIMEMsgPack2}
+
+// acceptRange Accept请求头中的一项媒体范围
+type acceptRange struct {
+	mime    string
+	quality float64
+}
+
+// Negotiate 根据Accept请求头协商响应格式并输出data
+// offers为空时可协商JSON、XML、MsgPack；offers中没有对应renderer的MIME会被忽略；无可接受格式时响应406
+func (c *Context) Negotiate(code int, data Result, offers ...string) (err error) {
+	c.guard()
+	if len(offers) == 0 {
+		offers = defaultOffers
+	}
+
+	c.addVary("Accept")
+	switch strings.ToLower(c.NegotiateFormat(renderableOffers(offers)...)) {
+	case MIMEJSON:
+		return c.JSON(code, data)
+	case MIMEXML, MIMEXML2:
+		return c.XML(code, data)
+	case MIMEMsgPack, MIMEMsgPack2:
+		return c.MsgPack(code, data)
+	}
+
+	c.AbortStatus(http.StatusNotAcceptable)
+	return
+}
+
+// NegotiateFormat 返回offers中最符合Accept请求头的MIME，若无可接受的MIME则返回空字符串
+// Accept请求头为空时返回offers[0]
+func (c *Context) NegotiateFormat(offers ...string) string {
+	c.guard()
+	if len(offers) == 0 {
+		return ""
+	}
+
+	accepts := parseAccept(c.Request.Header.Get("Accept"))
+	if len(accepts) == 0 {
+		return offers[0]
+	}
+
+	best, bestQuality := "", 0.0
+	for _, offer := range offers {
+		if quality := acceptQuality(accepts, offer); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+
+	return best
+}
+
+// renderableOffers 返回offers中有对应renderer的MIME
+func renderableOffers(offers []string) []string {
+	renderable := make([]string, 0, len(offers))
+	for _, offer := range offers {
+		switch strings.ToLower(offer) {
+		case MIMEJSON, MIMEXML, MIMEXML2, MIMEMsgPack, MIMEMsgPack2:
+			renderable = append(renderable, offer)
+		}
+	}
+	return renderable
+}
+
+// addVary 追加Vary响应头，已存在时不重复追加
+func (c *Context) addVary(field string) {
+	header := c.responser.Header()
+	for _, value := range header.Values("Vary") {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
+
+// parseAccept 解析Accept请求头
+func parseAccept(header string) (accepts []acceptRange) {
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mime := strings.ToLower(strings.TrimSpace(params[0]))
+		if mime == "" {
+			continue
+		}
+
+		accept := acceptRange{mime: mime, quality: 1}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q >= 0 && q <= 1 {
+					accept.quality = q
+				}
+			}
+		}
+		accepts = append(accepts, accept)
+	}
+	return
+}
+
+// acceptQuality 返回offer在accepts中最精确匹配项的q值
+// 匹配精度：type/subtype > type/* > */*
+func acceptQuality(accepts []acceptRange, offer string) float64 {
+	offer = strings.ToLower(offer)
+	offerType := offer
+	if i := strings.IndexByte(offer, '/'); i > 0 {
+		offerType = offer[:i]
+	}
+
+	quality, specificity := 0.0, -1
+	for _, accept := range accepts {
+		level := -1
+		switch {
+		case accept.mime == offer:
+			level = 2
+		case strings.HasSuffix(accept.mime, "/*") && accept.mime[:len(accept.mime)-2] == offerType:
+			level = 1
+		case accept.mime == "*/*" || accept.mime == "*":
+			level = 0
+		}
+		if level > specificity {
+			quality, specificity = accept.quality, level
+		}
+	}
+
+	return quality
+}
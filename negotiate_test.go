@@ -0,0 +1,160 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", []string{MIMEJSON, MIMEXML}, MIMEJSON},
+		{"application/xml", []string{MIMEJSON, MIMEXML}, MIMEXML},
+		{"application/json;q=0.5, application/xml;q=0.9", []string{MIMEJSON, MIMEXML}, MIMEXML},
+		{"Application/JSON", []string{MIMEXML, MIMEJSON}, MIMEJSON},
+		{"text/*", []string{MIMEJSON, MIMEXML2}, MIMEXML2},
+		{"*/*;q=0.1, application/json", []string{MIMEXML, MIMEJSON}, MIMEJSON},
+		{"application/*;q=0.2, application/json;q=0.8", []string{MIMEXML, MIMEJSON}, MIMEJSON},
+		{"application/json;q=0, */*", []string{MIMEJSON, MIMEXML}, MIMEXML},
+		{"application/json;q=0", []string{MIMEJSON}, ""},
+		{"image/png", []string{MIMEJSON, MIMEXML}, ""},
+		{"application/json", nil, ""},
+	}
+	for _, tt := range tests {
+		s := New()
+		c := newContext(s)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		c.init(httptest.NewRecorder(), req)
+		if got := c.NegotiateFormat(tt.offers...); got != tt.want {
+			t.Errorf("NegotiateFormat(%q, %v) = %q, want %q", tt.accept, tt.offers, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept      string
+		offers      []string
+		status      int
+		contentType string
+	}{
+		{"", nil, http.StatusOK, ContentTypeJSON},
+		{"application/xml", nil, http.StatusOK, ContentTypeXML},
+		{"text/xml", nil, http.StatusOK, ContentTypeXML},
+		{"application/msgpack;q=0.9, application/json;q=0.1", nil, http.StatusOK, ContentTypeMsgPack},
+		{"image/png", nil, http.StatusNotAcceptable, ""},
+		{"text/html", []string{"text/html", MIMEXML}, http.StatusNotAcceptable, ""},
+		{"text/html, application/xml;q=0.1", []string{"text/html", MIMEXML}, http.StatusOK, ContentTypeXML},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.GET("/", func(c *Context) {
+			c.Negotiate(http.StatusOK, Result{Message: "ok"}, tt.offers...)
+		})
+		s.buildTrees()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		w := do(s, req)
+		if w.Code != tt.status || w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %q, offers %v: %d %q, want %d %q", tt.accept, tt.offers, w.Code, w.Header().Get("Content-Type"), tt.status, tt.contentType)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, got)
+		}
+	}
+}
+
+type xmlUser struct {
+	Name string `xml:"name"`
+	Age  int    `xml:"age"`
+}
+
+func TestXMLRender(t *testing.T) {
+	tests := []struct {
+		data Any
+		want string
+	}{
+		{nil, `<Result><code>0</code><message></message><data></data></Result>`},
+		{AnyMap{"b": 2, "a": "x"}, `<Result><code>0</code><message></message><data><a>x</a><b>2</b></data></Result>`},
+		{map[string]interface{}{
+			"name":   "nets",
+			"tags":   []string{"a", "b"},
+			"nested": map[string]int{"n": 1},
+		}, `<Result><code>0</code><message></message><data><name>nets</name><nested><n>1</n></nested><tags>a</tags><tags>b</tags></data></Result>`},
+		{map[int]string{1: "one"}, `<Result><code>0</code><message></message><data><entry key="1">one</entry></data></Result>`},
+		{map[string]string{"1a": "x", "a b": "y", "xmlns": "z", "<a>": "w"},
+			`<Result><code>0</code><message></message><data><entry key="1a">x</entry><entry key="&lt;a&gt;">w</entry>` +
+				`<entry key="a b">y</entry><entry key="xmlns">z</entry></data></Result>`},
+		{[]map[string]int{{"n": 1}, {"n": 2}}, `<Result><code>0</code><message></message><data><n>1</n></data><data><n>2</n></data></Result>`},
+		{xmlUser{Name: "nets", Age: 3}, `<Result><code>0</code><message></message><data><name>nets</name><age>3</age></data></Result>`},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.GET("/", func(c *Context) {
+			c.XML(http.StatusOK, Result{Data: tt.data})
+		})
+		s.buildTrees()
+
+		w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("XML(%v) = %d %s, want %s", tt.data, w.Code, w.Body.String(), tt.want)
+		}
+	}
+}
+
+type msgPackBase struct {
+	ID int `json:"id"`
+}
+
+type msgPackUser struct {
+	msgPackBase
+	Name  string `msgpack:"name"`
+	Email string `json:"email,omitempty"`
+	Skip  string `json:"-"`
+}
+
+func TestMsgPackRender(t *testing.T) {
+	tests := []struct {
+		data Any
+		want string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{-1, "ff"},
+		{200, "ccc8"},
+		{"ok", "a26f6b"},
+		{[]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{map[string]int{"b": 2, "a": 1}, "82a16101a16202"},
+		{msgPackUser{msgPackBase: msgPackBase{ID: 1}, Name: "n"}, "82a2696401a46e616d65a16e"},
+	}
+	for _, tt := range tests {
+		data, err := marshalMsgPack(tt.data)
+		if err != nil || hex.EncodeToString(data) != tt.want {
+			t.Errorf("marshalMsgPack(%v) = %x, %v, want %s", tt.data, data, err, tt.want)
+		}
+	}
+
+	s := New()
+	s.GET("/", func(c *Context) {
+		c.MsgPack(http.StatusOK, Result{Message: "ok"})
+	})
+	s.buildTrees()
+	w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+	// {"code":0,"message":"ok","data":{}}
+	want := "83a4636f646500a76d657373616765a26f6ba46461746180"
+	if w.Header().Get("Content-Type") != ContentTypeMsgPack || hex.EncodeToString(w.Body.Bytes()) != want {
+		t.Errorf("MsgPack = %q %x, want %s", w.Header().Get("Content-Type"), w.Body.Bytes(), want)
+	}
+}
@@ -5,8 +5,10 @@
 package nets
 
 import (
+	"context"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,18 +21,37 @@ import (
 
 // Server Net server
 type Server struct {
-	router             // 路由
-	Config *Configure  // 配置
-	pool   sync.Pool   // the pool of nets context
-	metas  methodMetas // Stores the routing registration metadata of each HTTP method
-	trees  methodTrees // Stores the routing prefix tree of each HTTP method
-	trace  HandlerFunc // trace handle func
+	router               // 路由
+	Config  *Configure   // 配置
+	pool    sync.Pool    // the pool of nets context
+	metas   methodMetas  // Stores the routing registration metadata of each HTTP method
+	trees   methodTrees  // Stores the routing prefix tree of each HTTP method
+	trace   HandlerChain // trace handle funcs
+	noRoute HandlerChain // handle funcs for unmatched requests
+	json    JSONCodec    // json codec
+
+	errorMapper    ErrorMapper      // error handler mapper
+	tracePipelines []*tracePipeline // async trace pipelines
+	httpServer     *http.Server     // running http server
+	httpServerMu   sync.Mutex       // httpServer lock
+	contexts       uint64           // number of contexts allocated by pool
+	health         *HealthRegistry  // health checks
+	draining       int32            // set to 1 when shutting down
 }
 
 // New return new *Server
 func New() (s *Server) {
-	s = &Server{Config: newConfig(), metas: make(methodMetas, 0, 10)}
-	s.pool.New = func() interface{} { return newContext(s) }
+	s = &Server{
+		Config:      newConfig(),
+		metas:       make(methodMetas, 0, 10),
+		json:        newJSONCodec(),
+		errorMapper: DefaultErrorMapper,
+	}
+	s.health = newHealthRegistry(s)
+	s.pool.New = func() interface{} {
+		atomic.AddUint64(&s.contexts, 1)
+		return newContext(s)
+	}
 	s.router = router{basePath: "/", server: s}
 	return
 }
@@ -41,7 +62,7 @@ func (s *Server) Run(addr ...string) (err error) {
 	s.buildTrees()
 	address := IndexOfStrings(addr, 0, defaultHTTPServerAddr)
 	debugPrintf("Listening and serving HTTP on %s\n", address)
-	err = http.ListenAndServe(address, s)
+	err = s.newHTTPServer(address).ListenAndServe()
 	return
 }
 
@@ -50,14 +71,64 @@ func (s *Server) RunTLS(addr, certFile, keyFile string) (err error) {
 	defer func() { debugPrintError(err) }()
 	s.buildTrees()
 	debugPrintf("Listening and serving HTTPS on %s\n", addr)
-	err = http.ListenAndServeTLS(addr, certFile, keyFile, s)
+	err = s.newHTTPServer(addr).ListenAndServeTLS(certFile, keyFile)
 	return
 }
 
-// Trace 注册请求结束后执行的中间件
-func (s *Server) Trace(handler HandlerFunc) {
+// Shutdown 优雅关闭：就绪检查立即返回draining，等待Configure.SetShutdownDelay设置的时间后停止接收新连接，
+// 等待进行中的请求结束，随后处理完异步trace队列中剩余的记录；Run、RunTLS随即返回http.ErrServerClosed，
+// 之后可再次调用Run、RunTLS启动
+func (s *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.draining, 1)
+	if delay := s.Config.shutdownDelay; delay > 0 {
+		timer := time.NewTimer(delay)
+		// ctx结束时只缩短等待，仍然停止接收新连接并处理剩余的trace
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	s.httpServerMu.Lock()
+	server := s.httpServer
+	s.httpServerMu.Unlock()
+
+	var err error
+	if server != nil {
+		err = server.Shutdown(ctx)
+	}
+	if flushErr := s.flushTraces(ctx); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// Draining 是否正在优雅关闭
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// newHTTPServer 创建并记录http.Server，重新启动时清除上次Shutdown设置的draining并重新打开异步trace pipeline
+func (s *Server) newHTTPServer(addr string) *http.Server {
+	s.httpServerMu.Lock()
+	defer s.httpServerMu.Unlock()
+	s.httpServer = &http.Server{Addr: addr, Handler: s}
+	s.reopenTraces()
+	atomic.StoreInt32(&s.draining, 0)
+	return s.httpServer
+}
+
+// Trace 注册请求结束后执行的中间件，多次注册时按注册顺序执行
+func (s *Server) Trace(handlers ...HandlerFunc) {
 	s.Config.trace = true
-	s.trace = handler
+	s.trace = append(s.trace, handlers...)
+}
+
+// NoRoute 注册路由未命中时执行的handler，执行前响应状态已设为404，handler未输出响应时响应404
+// 可用于统计或记录未命中的请求，如s.NoRoute(metrics.Middleware())
+func (s *Server) NoRoute(handlers ...HandlerFunc) {
+	s.noRoute = append(s.noRoute, handlers...)
 }
 
 // ServeHTTP 实现http.Handler接口
@@ -65,10 +136,34 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c := s.pool.Get().(*Context)
 	c.init(w, r)
 	s.handleHTTPRequest(c)
-	if s.trace != nil {
-		s.trace(c)
+	c.stopHeartbeats()
+	if s.Config.trace {
+		c.closeSpans()
+		// trace handler对每个请求都会执行；未被采样的请求不记录请求参数、请求头和结果数据，
+		// 不放入异步trace pipeline，ExportSpans也不会导出
+		if c.Trace.Sampled = c.traceSampled(); c.Trace.Sampled {
+			c.captureTraceParams()
+			c.Trace.Headers = c.Request.Header.Clone()
+			c.redactTrace()
+		} else {
+			c.Trace.Data = nil
+		}
+		for _, handler := range s.trace {
+			handler(c)
+		}
+		if len(s.tracePipelines) > 0 && c.Trace.Sampled {
+			record := c.traceRecord()
+			for _, p := range s.tracePipelines {
+				p.enqueue(record)
+			}
+		}
 	}
 	c.reset()
+	if s.Config.detectContextLeak {
+		// 检测context泄漏时不复用context，以便检测请求结束后仍被goroutine使用的context
+		c.release()
+		return
+	}
 	s.pool.Put(c)
 }
 
@@ -84,11 +179,19 @@ func (s *Server) handleHTTPRequest(ctx *Context) {
 	method, path := ctx.Request.Method, ctx.Request.URL.Path
 	if route, ok := routeValue(s.trees.get(method).root, method, path, true); ok {
 		ctx.params = route.params
+		ctx.fullPath = route.path
+		ctx.handlerNames = route.names
+		if s.Config.trace {
+			ctx.Trace.Route = route.path
+			ctx.Trace.Handler = ctx.HandlerName()
+		}
 		ctx.handlers = route.handlers
 		ctx.Next()
 		return
 	}
 
+	ctx.handlers = s.noRoute
+	ctx.responser.WriteHeader(http.StatusNotFound)
 	ctx.Next()
 	ctx.AbortStatus(http.StatusNotFound)
 }
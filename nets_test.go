@@ -0,0 +1,32 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// mark 返回在响应头X-Handlers中记录自身名称的handler
+func mark(name string) HandlerFunc {
+	return func(c *Context) {
+		c.responser.Header().Add("X-Handlers", name)
+	}
+}
+
+// serve 发起请求，返回http status和依次执行的handler名称
+func serve(s *Server, method, path string) (int, string) {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code, strings.Join(w.Header().Values("X-Handlers"), ",")
+}
+
+// do 发起请求并返回响应
+func do(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	// otlp instrumentation scope name
+	otlpScopeName string = "nets"
+
+	// otlp status code
+	otlpStatusUnset int = 0
+	otlpStatusError int = 2
+)
+
+// OTLPJSONExporter 以OTLP/JSON格式（ExportTraceServiceRequest）导出span的SpanExporter，
+// 每次导出写入一行json，可写入文件或标准输出，无需collector即可查看和测试
+type OTLPJSONExporter struct {
+	w           io.Writer
+	mu          sync.Mutex
+	serviceName string
+}
+
+// NewOTLPJSONExporter 返回写入w的OTLPJSONExporter，w为nil时使用os.Stdout
+func NewOTLPJSONExporter(w io.Writer, serviceName string) *OTLPJSONExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &OTLPJSONExporter{w: w, serviceName: serviceName}
+}
+
+// NewOTLPJSONFileExporter 返回追加写入文件filename的OTLPJSONExporter，不再使用时需调用Close
+func NewOTLPJSONFileExporter(filename, serviceName string) (*OTLPJSONExporter, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return NewOTLPJSONExporter(file, serviceName), nil
+}
+
+// ExportSpans 实现SpanExporter接口
+func (exporter *OTLPJSONExporter) ExportSpans(spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(exporter.request(spans))
+	if err != nil {
+		return err
+	}
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	_, err = exporter.w.Write(append(data, '\n'))
+	return err
+}
+
+// Close 关闭底层的io.Writer（若实现了io.Closer）
+func (exporter *OTLPJSONExporter) Close() error {
+	if closer, ok := exporter.w.(io.Closer); ok && exporter.w != os.Stdout && exporter.w != os.Stderr {
+		return closer.Close()
+	}
+	return nil
+}
+
+// otlpRequest OTLP ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// request 构造OTLP请求
+func (exporter *OTLPJSONExporter) request(spans []*Span) otlpRequest {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, newOTLPSpan(span))
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: newOTLPAnyValue(exporter.serviceName)},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: otlpScopeName},
+			Spans: otlpSpans,
+		}},
+	}}}
+}
+
+// newOTLPSpan 转换为OTLP span
+func newOTLPSpan(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	s := otlpSpan{
+		TraceID:           span.TraceID,
+		SpanID:            span.SpanID,
+		ParentSpanID:      span.ParentID,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Status:            otlpStatus{Code: otlpStatusUnset},
+	}
+	if span.Error != "" {
+		s.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+	}
+
+	keys := make([]string, 0, len(span.Attributes))
+	for k := range span.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s.Attributes = append(s.Attributes, otlpKeyValue{Key: k, Value: newOTLPAnyValue(span.Attributes[k])})
+	}
+	return s
+}
+
+// newOTLPAnyValue 转换为OTLP AnyValue，不支持的类型使用字符串表示
+func newOTLPAnyValue(value Any) otlpAnyValue {
+	switch v := value.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		return otlpAnyValue{IntValue: &s}
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	default:
+		s := fmt.Sprint(v)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
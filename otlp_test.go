@@ -0,0 +1,80 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOTLPJSONExporter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	exporter := NewOTLPJSONExporter(buf, "svc")
+	spans := []*Span{
+		{
+			TraceID:   testTraceID,
+			SpanID:    testParentID,
+			Name:      "GET /users/:id",
+			Kind:      SpanKindServer,
+			StartTime: time.Unix(1, 0),
+			EndTime:   time.Unix(1, 500000000),
+			Attributes: map[string]Any{
+				"http.status_code": 500,
+				"http.route":       "/users/:id",
+				"ok":               true,
+				"ratio":            0.5,
+				"ids":              []int{1, 2},
+			},
+			Error: "boom",
+		},
+		{
+			TraceID:   testTraceID,
+			SpanID:    "00f067aa0ba902b7",
+			ParentID:  testParentID,
+			Name:      "db",
+			Kind:      SpanKindInternal,
+			StartTime: time.Unix(1, 100000000),
+			EndTime:   time.Unix(1, 200000000),
+		},
+	}
+
+	if err := exporter.ExportSpans(nil); err != nil || buf.Len() != 0 {
+		t.Fatalf("ExportSpans(nil) = %v, wrote %q", err, buf.String())
+	}
+	if err := exporter.ExportSpans(spans); err != nil {
+		t.Fatalf("ExportSpans = %v", err)
+	}
+	if err := exporter.ExportSpans(spans[1:]); err != nil {
+		t.Fatalf("ExportSpans = %v", err)
+	}
+
+	server := `{"traceId":"` + testTraceID + `","spanId":"` + testParentID + `","name":"GET /users/:id","kind":2,` +
+		`"startTimeUnixNano":"1000000000","endTimeUnixNano":"1500000000","attributes":[` +
+		`{"key":"http.route","value":{"stringValue":"/users/:id"}},` +
+		`{"key":"http.status_code","value":{"intValue":"500"}},` +
+		`{"key":"ids","value":{"stringValue":"[1 2]"}},` +
+		`{"key":"ok","value":{"boolValue":true}},` +
+		`{"key":"ratio","value":{"doubleValue":0.5}}],` +
+		`"status":{"code":2,"message":"boom"}}`
+	db := `{"traceId":"` + testTraceID + `","spanId":"00f067aa0ba902b7","parentSpanId":"` + testParentID + `","name":"db","kind":1,` +
+		`"startTimeUnixNano":"1100000000","endTimeUnixNano":"1200000000","status":{"code":0}}`
+	request := func(spans string) string {
+		return `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"svc"}}]},` +
+			`"scopeSpans":[{"scope":{"name":"` + otlpScopeName + `"},"spans":[` + spans + `]}]}]}`
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{request(server + "," + db), request(db)}
+	if len(lines) != len(want) {
+		t.Fatalf("exporter wrote %d lines, want %d:\n%s", len(lines), len(want), buf.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d:\n got %s\nwant %s", i, lines[i], want[i])
+		}
+	}
+}
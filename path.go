@@ -83,3 +83,20 @@ func parseCleanPath(basePath, relativePath string) (baspath, abspath string, par
 
 	return
 }
+
+// restorePattern 将路由参数名称按顺序填回pattern，如 /users/: => /users/:id
+func restorePattern(pattern string, paramKeys []string) string {
+	if len(paramKeys) == 0 {
+		return pattern
+	}
+
+	bytes, k := make([]byte, 0, len(pattern)+len(paramKeys)*8), 0
+	for i := 0; i < len(pattern); i++ {
+		bytes = append(bytes, pattern[i])
+		if pattern[i] == routeParamIdentifierByte && i > 0 && pattern[i-1] == slashByte && k < len(paramKeys) {
+			bytes = append(bytes, paramKeys[k]...)
+			k++
+		}
+	}
+	return string(bytes)
+}
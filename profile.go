@@ -0,0 +1,42 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"time"
+)
+
+// HandlerTiming 单个handler的耗时
+type HandlerTiming struct {
+	Name     string        `json:"name"`     // handler函数名称
+	Duration time.Duration `json:"duration"` // 耗时，包含其通过Next调用的后续handler
+	Self     time.Duration `json:"self"`     // 自身耗时，不包含后续handler
+}
+
+// Profile 开启当前请求后续handler的耗时记录，结果保存在Context.Trace.Timings
+func (c *Context) Profile() {
+	c.guard()
+	c.profiling = true
+}
+
+// profileHandler 执行第index个handler并记录耗时
+func (c *Context) profileHandler(index int8) {
+	name := ""
+	if int(index) < len(c.handlerNames) {
+		name = c.handlerNames[index]
+	}
+	c.Trace.Timings = append(c.Trace.Timings, HandlerTiming{Name: name})
+	pos, parentCost := len(c.Trace.Timings)-1, c.childCost
+
+	c.childCost = 0
+	start := time.Now()
+	defer func() {
+		cost := time.Since(start)
+		c.Trace.Timings[pos].Duration = cost
+		c.Trace.Timings[pos].Self = cost - c.childCost
+		c.childCost = parentCost + cost
+	}()
+	c.handlers[index](c)
+}
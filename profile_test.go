@@ -0,0 +1,77 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sleepy 返回先等待d再调用后续handler的中间件
+func sleepy(d time.Duration) HandlerFunc {
+	return func(c *Context) {
+		time.Sleep(d)
+		c.Next()
+	}
+}
+
+func TestProfiling(t *testing.T) {
+	s := New()
+	s.Config.SetProfiling(true)
+	var timings []HandlerTiming
+	s.Trace(func(c *Context) { timings = c.Trace.Timings })
+	s.GET("/", sleepy(10*time.Millisecond), func(c *Context) { time.Sleep(20 * time.Millisecond) })
+	s.buildTrees()
+
+	serve(s, http.MethodGet, "/")
+	if len(timings) < 2 {
+		t.Fatalf("Timings = %+v, want at least 2", timings)
+	}
+	middleware, handler := timings[len(timings)-2], timings[len(timings)-1]
+	if !strings.Contains(middleware.Name, "sleepy") || !strings.Contains(handler.Name, "TestProfiling") {
+		t.Errorf("Timings names = %s, %s", middleware.Name, handler.Name)
+	}
+	if middleware.Duration < 30*time.Millisecond || middleware.Self < 10*time.Millisecond || middleware.Self > middleware.Duration-handler.Duration {
+		t.Errorf("middleware timing = %+v, handler %+v", middleware, handler)
+	}
+	if handler.Duration < 20*time.Millisecond || handler.Self != handler.Duration {
+		t.Errorf("handler timing = %+v", handler)
+	}
+}
+
+func TestProfileOptIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		profiling bool
+		breakdown bool
+		path      string
+		timings   bool
+	}{
+		{"off", false, false, "/slow", false},
+		{"profile", false, false, "/profile", true},
+		{"breakdown fast", false, true, "/fast", false},
+		{"breakdown slow", false, true, "/slow", true},
+		{"profiling fast", true, false, "/fast", true},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.Config.SetProfiling(tt.profiling)
+		watchdog := NewWatchdog(WatchdogConfig{Threshold: 10 * time.Millisecond, Output: &syncBuffer{}, Breakdown: tt.breakdown})
+		var timings []HandlerTiming
+		s.Trace(func(c *Context) { timings = c.Trace.Timings })
+		s.Use(watchdog.Middleware())
+		s.GET("/fast", func(c *Context) {})
+		s.GET("/slow", func(c *Context) { time.Sleep(20 * time.Millisecond) })
+		s.GET("/profile", func(c *Context) { c.Profile(); c.Next() }, func(c *Context) {})
+		s.buildTrees()
+
+		serve(s, http.MethodGet, tt.path)
+		if (len(timings) > 0) != tt.timings {
+			t.Errorf("%s: Timings = %+v, want recorded %v", tt.name, timings, tt.timings)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package nets
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"syscall"
 )
 
 // Recovery returns a middleware that recovers from any panics and writes a 500 if there was one.
@@ -30,13 +32,8 @@ func Recovery() HandlerFunc {
 // recovery record stack and abort
 func recovery(ctx *Context, err interface{}) {
 	var brokenPipe bool
-	if ne, ok := err.(*net.OpError); ok {
-		if se, ok := ne.Err.(*os.SyscallError); ok {
-			lowerSeErr := strings.ToLower(se.Error())
-			if strings.Contains(lowerSeErr, "broken pipe") || strings.Contains(lowerSeErr, "connection reset by peer") {
-				brokenPipe = true
-			}
-		}
+	if e, ok := err.(error); ok {
+		brokenPipe = isBrokenPipe(e)
 	}
 
 	errStack := stack(4, err)
@@ -48,13 +45,29 @@ func recovery(ctx *Context, err interface{}) {
 		println(string(errStack))
 	}
 
-	if brokenPipe {
+	// 客户端已断开或响应头已写出（如sse长连接）时，不再改写http status
+	if brokenPipe || ctx.responser.Written() {
 		ctx.Abort()
 	} else {
 		ctx.AbortStatus(http.StatusInternalServerError)
 	}
 }
 
+// isBrokenPipe 判断err是否为客户端断开连接导致的错误
+func isBrokenPipe(err error) bool {
+	var ne *net.OpError
+	if errors.As(err, &ne) {
+		var se *os.SyscallError
+		if errors.As(ne.Err, &se) {
+			lowerSeErr := strings.ToLower(se.Error())
+			if strings.Contains(lowerSeErr, "broken pipe") || strings.Contains(lowerSeErr, "connection reset by peer") {
+				return true
+			}
+		}
+	}
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
 // stack 错误栈
 func stack(skip int, err interface{}) []byte {
 	var lines [][]byte
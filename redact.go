@@ -0,0 +1,169 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 默认脱敏掩码
+	defaultTraceRedactMask string = "***"
+)
+
+// defaultTraceRedactKeys 默认脱敏的参数名、请求头名和数据字段名（不区分大小写）
+var defaultTraceRedactKeys = []string{
+	"password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token",
+	"authorization", "proxy-authorization", "cookie", "set-cookie", "api_key", "apikey", "x-api-key",
+}
+
+// traceRedactor trace脱敏规则
+// keys和patterns匹配参数名、请求头名以及结果数据中任意层级的字段名，
+// paths匹配"params.xxx"、"headers.xxx"、"data.xxx.yyy"形式的路径，"*"匹配任意一段
+type traceRedactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+	paths    [][]string
+	mask     string
+}
+
+// newTraceRedactor return the default trace redactor
+func newTraceRedactor() traceRedactor {
+	r := traceRedactor{mask: defaultTraceRedactMask}
+	r.setKeys(defaultTraceRedactKeys)
+	return r
+}
+
+// setKeys 设置脱敏字段名
+func (r *traceRedactor) setKeys(keys []string) {
+	r.keys = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		r.keys[strings.ToLower(key)] = true
+	}
+}
+
+// empty 是否没有任何规则
+func (r *traceRedactor) empty() bool {
+	return len(r.keys) == 0 && len(r.patterns) == 0 && len(r.paths) == 0
+}
+
+// matchKey 字段名是否匹配keys或patterns
+func (r *traceRedactor) matchKey(key string) bool {
+	if r.keys[strings.ToLower(key)] {
+		return true
+	}
+	for _, pattern := range r.patterns {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath 路径是否匹配paths
+func (r *traceRedactor) matchPath(path []string) bool {
+	for _, p := range r.paths {
+		if len(p) != len(path) {
+			continue
+		}
+		matched := true
+		for i := range p {
+			if p[i] != "*" && !strings.EqualFold(p[i], path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// values 返回脱敏后的副本，root为路径前缀（params或headers）
+func (r *traceRedactor) values(root string, values map[string][]string) map[string][]string {
+	if values == nil {
+		return nil
+	}
+
+	redacted := make(map[string][]string, len(values))
+	for key, vs := range values {
+		if r.matchKey(key) || r.matchPath([]string{root, key}) {
+			masks := make([]string, len(vs))
+			for i := range masks {
+				masks[i] = r.mask
+			}
+			redacted[key] = masks
+			continue
+		}
+		redacted[key] = vs
+	}
+	return redacted
+}
+
+// data 返回脱敏后的结果数据，数据先经codec转换为通用的map/slice结构，不修改原数据
+// 无法转换时整体替换为mask，避免未脱敏的数据被记录
+func (r *traceRedactor) data(data Any, codec JSONCodec) Any {
+	if data == nil {
+		return nil
+	}
+
+	bytes, err := codec.Marshal(data)
+	if err != nil {
+		return r.mask
+	}
+	var generic interface{}
+	if err = codec.Unmarshal(bytes, &generic); err != nil {
+		return r.mask
+	}
+	return r.walk(generic, []string{"data"})
+}
+
+// walk 递归脱敏通用结构
+func (r *traceRedactor) walk(value interface{}, path []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			itemPath := append(path[:len(path):len(path)], key)
+			if r.matchKey(key) || r.matchPath(itemPath) {
+				v[key] = r.mask
+				continue
+			}
+			v[key] = r.walk(item, itemPath)
+		}
+	case []interface{}:
+		for i, item := range v {
+			itemPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if r.matchPath(itemPath) {
+				v[i] = r.mask
+				continue
+			}
+			v[i] = r.walk(item, itemPath)
+		}
+	}
+	return value
+}
+
+// redactTrace 按Configure的脱敏规则处理trace中的请求参数、请求头和结果数据
+func (c *Context) redactTrace() {
+	r := &c.server.Config.traceRedactor
+	if r.empty() {
+		return
+	}
+
+	if params, ok := c.Trace.Params.(url.Values); ok {
+		c.Trace.Params = url.Values(r.values("params", params))
+	}
+	if c.Trace.Headers != nil {
+		c.Trace.Headers = http.Header(r.values("headers", c.Trace.Headers))
+	}
+	if c.Trace.Data != nil {
+		c.Trace.Data = r.data(c.Trace.Data, c.server.json)
+	}
+}
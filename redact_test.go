@@ -0,0 +1,214 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleTraceID(t *testing.T) {
+	low, high := testTraceID[:16]+"0000000000000001", testTraceID[:16]+"ffffffffffffffff"
+	tests := []struct {
+		traceID string
+		ratio   float64
+		sampled bool
+	}{
+		{high, 1, true},
+		{low, 0, false},
+		{low, 0.5, true},
+		{high, 0.5, false},
+		{high, 0.999, false},
+		{"bad", 0.5, false},
+		{strings.Repeat("z", 32), 0.5, false},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 3; i++ {
+			if got := sampleTraceID(tt.traceID, tt.ratio); got != tt.sampled {
+				t.Errorf("sampleTraceID(%s, %v) = %v, want %v", tt.traceID, tt.ratio, got, tt.sampled)
+			}
+		}
+	}
+}
+
+func TestTraceSampling(t *testing.T) {
+	tests := []struct {
+		name        string
+		ratio       float64
+		traceParent string
+		status      int
+		sleep       time.Duration
+		noErrors    bool
+		sampled     bool
+	}{
+		{"ratio 1", 1, "", http.StatusOK, 0, false, true},
+		{"ratio 0", 0, "", http.StatusOK, 0, false, false},
+		{"upstream sampled", 0, "00-" + testTraceID + "-" + testParentID + "-01", http.StatusOK, 0, false, true},
+		{"upstream not sampled", 1, "00-" + testTraceID + "-" + testParentID + "-00", http.StatusOK, 0, false, false},
+		{"error upgraded", 0, "", http.StatusInternalServerError, 0, false, true},
+		{"error not upgraded", 0, "", http.StatusInternalServerError, 0, true, false},
+		{"slow upgraded", 0, "", http.StatusOK, 20 * time.Millisecond, false, true},
+	}
+	for _, tt := range tests {
+		sink, exporter := &recordSink{}, &memExporter{}
+		s := New()
+		s.Config.SetRecordResultData(true)
+		s.Config.SetTraceSampleRatio(tt.ratio)
+		s.Config.SetTraceSampleErrors(!tt.noErrors)
+		s.Config.SetTraceSlowThreshold(10 * time.Millisecond)
+		var trace trace
+		s.Trace(func(c *Context) { trace = c.Trace }, ExportSpans(exporter))
+		s.TraceAsync(sink)
+		s.POST("/users", func(c *Context) {
+			time.Sleep(tt.sleep)
+			c.JSON(tt.status, Result{Data: AnyMap{"name": "nets"}})
+		})
+		s.buildTrees()
+
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader("name=nets"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(HeaderTraceParent, tt.traceParent)
+		do(s, req)
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Fatalf("%s: Shutdown = %v", tt.name, err)
+		}
+
+		recorded := trace.Params != nil && trace.Headers != nil && trace.Data != nil
+		cleared := trace.Params == nil && trace.Headers == nil && trace.Data == nil
+		if trace.Sampled != tt.sampled || (tt.sampled && !recorded) || (!tt.sampled && !cleared) {
+			t.Errorf("%s: Sampled = %v, params %v, headers %v, data %v", tt.name, trace.Sampled, trace.Params, trace.Headers, trace.Data)
+		}
+		want := 0
+		if tt.sampled {
+			want = 1
+		}
+		if len(sink.paths()) != want || len(exporter.exports) != want {
+			t.Errorf("%s: enqueued %d, exported %d, want %d", tt.name, len(sink.paths()), len(exporter.exports), want)
+		}
+	}
+}
+
+func TestTraceRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(config *Configure)
+		params  string
+		headers string
+		data    string
+	}{
+		{
+			"default",
+			func(config *Configure) {},
+			`{"card_no":["1234"],"name":["nets"],"otp_code":["9"],"password":["***"]}`,
+			`{"Authorization":["***"],"X-Token":["t"]}`,
+			`{"list":[{"phone":"2"}],"user":{"name":"nets","phone":"1","token":"***"}}`,
+		},
+		{
+			"paths",
+			func(config *Configure) {
+				config.SetTraceRedactPaths("params.card_no", "headers.x-token", "data.user.phone", "data.list.*.phone")
+			},
+			`{"card_no":["***"],"name":["nets"],"otp_code":["9"],"password":["***"]}`,
+			`{"Authorization":["***"],"X-Token":["***"]}`,
+			`{"list":[{"phone":"***"}],"user":{"name":"nets","phone":"***","token":"***"}}`,
+		},
+		{
+			"patterns and mask",
+			func(config *Configure) {
+				config.SetTraceRedactPatterns(`_code$`, `^PHONE$`)
+				config.SetTraceRedactMask("[x]")
+			},
+			`{"card_no":["1234"],"name":["nets"],"otp_code":["[x]"],"password":["[x]"]}`,
+			`{"Authorization":["[x]"],"X-Token":["t"]}`,
+			`{"list":[{"phone":"2"}],"user":{"name":"nets","phone":"1","token":"[x]"}}`,
+		},
+		{
+			"keys replace defaults",
+			func(config *Configure) { config.SetTraceRedactKeys("Name") },
+			`{"card_no":["1234"],"name":["***"],"otp_code":["9"],"password":["x"]}`,
+			`{"Authorization":["Bearer x"],"X-Token":["t"]}`,
+			`{"list":[{"phone":"2"}],"user":{"name":"***","phone":"1","token":"t"}}`,
+		},
+		{
+			"no rules",
+			func(config *Configure) { config.SetTraceRedactKeys() },
+			`{"card_no":["1234"],"name":["nets"],"otp_code":["9"],"password":["x"]}`,
+			`{"Authorization":["Bearer x"],"X-Token":["t"]}`,
+			`{"list":[{"phone":"2"}],"user":{"name":"nets","phone":"1","token":"t"}}`,
+		},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.Config.SetRecordResultData(true)
+		tt.config(s.Config)
+		var trace trace
+		s.Trace(func(c *Context) { trace = c.Trace })
+		data := AnyMap{
+			"user": AnyMap{"name": "nets", "phone": "1", "token": "t"},
+			"list": []AnyMap{{"phone": "2"}},
+		}
+		s.POST("/login", func(c *Context) {
+			c.JSON(http.StatusOK, Result{Data: data})
+		})
+		s.buildTrees()
+
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=x&name=nets&card_no=1234&otp_code=9"))
+		req.Header = http.Header{
+			"Content-Type":  {"application/x-www-form-urlencoded"},
+			"Authorization": {"Bearer x"},
+			"X-Token":       {"t"},
+		}
+		do(s, req)
+
+		delete(trace.Headers, "Content-Type")
+		for _, v := range []struct {
+			field string
+			value interface{}
+			want  string
+		}{{"params", trace.Params, tt.params}, {"headers", trace.Headers, tt.headers}, {"data", trace.Data, tt.data}} {
+			if got, _ := json.Marshal(v.value); string(got) != v.want {
+				t.Errorf("%s: %s = %s, want %s", tt.name, v.field, got, v.want)
+			}
+		}
+		if data["user"].(AnyMap)["token"] != "t" {
+			t.Errorf("%s: redaction modified handler data", tt.name)
+		}
+	}
+}
+
+func TestTraceRedactFailClosed(t *testing.T) {
+	r := newTraceRedactor()
+	codec := newJSONCodec()
+	tests := []struct {
+		data Any
+		want Any
+	}{
+		{nil, nil},
+		{func() {}, defaultTraceRedactMask},
+		{AnyMap{"password": "x", "ch": make(chan int)}, defaultTraceRedactMask},
+		{url.Values{"token": {"t"}}, map[string]interface{}{"token": defaultTraceRedactMask}},
+	}
+	for _, tt := range tests {
+		got := r.data(tt.data, codec)
+		if gotJSON, wantJSON := mustJSON(got), mustJSON(tt.want); gotJSON != wantJSON {
+			t.Errorf("data(%T) = %s, want %s", tt.data, gotJSON, wantJSON)
+		}
+	}
+}
+
+// mustJSON 返回v的json字符串，用于比较通用结构
+func mustJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
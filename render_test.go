@@ -0,0 +1,71 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRenderFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback *Result // 为nil时使用默认配置
+		status   int
+		data     Any
+		code     int
+		body     string
+		failed   bool
+	}{
+		{"ok", nil, 0, AnyMap{"id": 1}, http.StatusOK, `{"code":0,"message":"","data":{"id":1}}`, false},
+		{"nil data", nil, 0, nil, http.StatusOK, `{"code":0,"message":"","data":{}}`, false},
+		{"default fallback", nil, 0, make(chan int), http.StatusInternalServerError,
+			`{"code":500,"message":"Internal Server Error","data":{}}`, true},
+		{"custom fallback", &Result{Code: 1001, Message: "encode failed"}, http.StatusServiceUnavailable, func() {},
+			http.StatusServiceUnavailable, `{"code":1001,"message":"encode failed","data":{}}`, true},
+		{"fallback fails", &Result{Data: make(chan int)}, http.StatusBadGateway, make(chan int), http.StatusBadGateway, "", true},
+	}
+	for _, tt := range tests {
+		s := New()
+		if tt.fallback != nil {
+			s.Config.SetRenderFallback(tt.status, *tt.fallback)
+		}
+		var renderErr error
+		s.GET("/", func(c *Context) {
+			renderErr = c.JSON(http.StatusOK, Result{Data: tt.data})
+		})
+		s.buildTrees()
+
+		w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+		code, body := w.Code, w.Body.String()
+		if code != tt.code || body != tt.body || (renderErr != nil) != tt.failed {
+			t.Errorf("%s: GET / = %d %q, err %v, want %d %q", tt.name, code, body, renderErr, tt.code, tt.body)
+		}
+	}
+}
+
+func TestIsBrokenPipe(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.EPIPE, true},
+		{syscall.ECONNRESET, true},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("write", syscall.EPIPE)}, true},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{errors.New("broken pipe"), false},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+	}
+	for _, tt := range tests {
+		if got := isBrokenPipe(tt.err); got != tt.want {
+			t.Errorf("isBrokenPipe(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
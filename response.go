@@ -41,8 +41,10 @@ type ResponseWriter interface {
 // responser http response writer
 type responser struct {
 	http.ResponseWriter
-	size   int
-	status int
+	size     int
+	status   int
+	hooks    []func() // 响应头写出前执行的函数
+	hijacked bool     // 连接是否已被接管
 }
 
 // reset reset response
@@ -50,6 +52,13 @@ func (r *responser) reset(w http.ResponseWriter) {
 	r.ResponseWriter = w
 	r.status = defaultStatus
 	r.size = noWrittenSize
+	r.hooks = nil
+	r.hijacked = false
+}
+
+// before 注册响应头写出前执行的函数，响应头已写出时不再执行
+func (r *responser) before(hook func()) {
+	r.hooks = append(r.hooks, hook)
 }
 
 // Status return the http response status
@@ -69,6 +78,7 @@ func (r *responser) Written() bool {
 
 // Write http.ResponseWriter.Write(data)
 func (r *responser) Write(data []byte) (n int, err error) {
+	r.WriteHeaderNow()
 	n, err = r.ResponseWriter.Write(data)
 	r.size += n
 	return
@@ -87,6 +97,11 @@ func (r *responser) WriteHeader(code int) {
 // WriteHeaderNow Forces to write the http header (status code + headers).
 func (r *responser) WriteHeaderNow() {
 	if !r.Written() {
+		hooks := r.hooks
+		r.hooks = nil
+		for _, hook := range hooks {
+			hook()
+		}
 		r.size = 0
 		r.ResponseWriter.WriteHeader(r.status)
 	}
@@ -97,7 +112,11 @@ func (r *responser) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if r.size < 0 {
 		r.size = 0
 	}
-	return r.ResponseWriter.(http.Hijacker).Hijack()
+	conn, rw, err := r.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		r.hijacked = true
+	}
+	return conn, rw, err
 }
 
 // CloseNotify implements the http.CloseNotify interface.
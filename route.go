@@ -11,7 +11,9 @@ import (
 
 type route struct {
 	pattern     string
+	path        string
 	handlers    HandlerChain
+	names       []string
 	params      Entries
 	paramKeys   []string
 	paramValues []string
@@ -82,9 +84,12 @@ func iterate(root *node, path string, pathLen, startx int, parentPattern string)
 	if ok {
 		value.pattern += root.pattern
 		value.handlers = combineHandlers(value.handlers, root.middlewares)
+		value.names = append(value.names, root.middlewareNames...)
 		if stopx == pathLen && len(root.handlers) > 0 {
 			value.paramKeys = root.paramKeys
+			value.path = root.path
 			value.handlers = combineHandlers(value.handlers, root.handlers)
+			value.names = append(value.names, root.handlerNames...)
 			return value, true
 		}
 
@@ -92,7 +97,9 @@ func iterate(root *node, path string, pathLen, startx int, parentPattern string)
 			if val, o := iterate(v, path, pathLen, stopx, root.pattern); o {
 				value.pattern += val.pattern
 				value.handlers = combineHandlers(value.handlers, val.handlers)
+				value.names = append(value.names, val.names...)
 				value.paramKeys = val.paramKeys
+				value.path = val.path
 				value.paramValues = append(value.paramValues, val.paramValues...)
 				return value, true
 			}
@@ -0,0 +1,138 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func routeAuth(c *Context) { c.Next() }
+
+func routeGetUser(c *Context) error { return nil }
+
+func routeListUsers(c *Context) {}
+
+func TestFullPath(t *testing.T) {
+	var fullPath, traceRoute, traceHandler string
+	s := New()
+	s.Trace(func(c *Context) { traceRoute, traceHandler = c.Trace.Route, c.Trace.Handler })
+	s.Group("/users/", routeAuth)
+	s.GET("/users/:id", E(routeGetUser), func(c *Context) { fullPath = c.FullPath() })
+	s.GET("/users/new", func(c *Context) { fullPath = c.FullPath() })
+	s.GET("/static/*", func(c *Context) { fullPath = c.FullPath() })
+	s.NoRoute(func(c *Context) { fullPath = c.FullPath() })
+	s.buildTrees()
+
+	tests := []struct {
+		path  string
+		route string
+	}{
+		{"/users/42", "/users/:id"},
+		{"/users/new", "/users/new"},
+		{"/static/css/app.css", "/static/*"},
+		{"/none", ""},
+	}
+	for _, tt := range tests {
+		fullPath, traceRoute = "-", "-"
+		serve(s, http.MethodGet, tt.path)
+		if fullPath != tt.route || traceRoute != tt.route {
+			t.Errorf("GET %s: FullPath %q, Trace.Route %q, want %q", tt.path, fullPath, traceRoute, tt.route)
+		}
+	}
+
+	serve(s, http.MethodGet, "/users/new")
+	if !strings.HasSuffix(traceHandler, ".TestFullPath.func3") {
+		t.Errorf("Trace.Handler = %q, want TestFullPath.func3", traceHandler)
+	}
+}
+
+func TestHandlerNames(t *testing.T) {
+	var name string
+	var names []string
+	s := New()
+	s.Group("/users/", routeAuth)
+	s.GET("/users/:id", func(c *Context) { name, names = c.HandlerName(), c.HandlerNames() }, E(routeGetUser))
+	s.GET("/users", routeListUsers)
+	s.NoRoute(func(c *Context) { name, names = c.HandlerName(), c.HandlerNames() })
+	s.buildTrees()
+
+	serve(s, http.MethodGet, "/users/42")
+	if name != "nets.routeGetUser" {
+		t.Errorf("HandlerName() = %q, want nets.routeGetUser", name)
+	}
+	if n := len(names); n < 3 || names[n-3] != "nets.routeAuth" || names[n-1] != "nets.routeGetUser" {
+		t.Errorf("HandlerNames() = %v, want [... nets.routeAuth nets.TestHandlerNames.func1 nets.routeGetUser]", names)
+	}
+
+	// 返回的是副本，修改不影响后续请求
+	names[len(names)-1] = "changed"
+	serve(s, http.MethodGet, "/users/42")
+	if names[len(names)-1] != "nets.routeGetUser" {
+		t.Errorf("HandlerNames() after modification = %v", names)
+	}
+
+	serve(s, http.MethodGet, "/none")
+	if name != "" || len(names) != 0 {
+		t.Errorf("unmatched HandlerName() = %q, HandlerNames() = %v, want empty", name, names)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s := New()
+	s.Group("/users/", routeAuth)
+	s.GET("/users/:id", E(routeGetUser))
+	s.POST("/users", routeListUsers)
+	s.GET("/users", routeListUsers)
+	s.buildTrees()
+
+	var got []RouteInfo
+	for _, route := range s.Routes() {
+		// 去掉New注册的默认中间件，只比较本测试注册的handler
+		handlers := route.Handlers
+		for len(handlers) > 0 && !strings.HasPrefix(handlers[0], "nets.route") {
+			handlers = handlers[1:]
+		}
+		route.Handlers = handlers
+		got = append(got, route)
+	}
+	want := []RouteInfo{
+		{Method: http.MethodGet, Path: "/users", Handler: "nets.routeListUsers", Handlers: []string{"nets.routeListUsers"}},
+		{Method: http.MethodPost, Path: "/users", Handler: "nets.routeListUsers", Handlers: []string{"nets.routeListUsers"}},
+		{Method: http.MethodGet, Path: "/users/:id", Handler: "nets.routeGetUser", Handlers: []string{"nets.routeAuth", "nets.routeGetUser"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMetricsRouteLabel(t *testing.T) {
+	m := NewMetrics()
+	s := New()
+	s.Use(m.Middleware())
+	s.NoRoute(m.Middleware())
+	s.GET("/users/:id", func(c *Context) {})
+	s.GET("/metrics", m.Expose())
+	s.buildTrees()
+
+	for _, path := range []string{"/users/1", "/users/2", "/none"} {
+		serve(s, http.MethodGet, path)
+	}
+	body := do(s, httptest.NewRequest(http.MethodGet, "/metrics", nil)).Body.String()
+	for _, line := range []string{
+		`nets_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`nets_http_requests_total{method="GET",route="",status="404"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `route="/users/1"`) {
+		t.Errorf("metrics labelled by raw path:\n%s", body)
+	}
+}
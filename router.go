@@ -6,12 +6,18 @@ package nets
 
 import (
 	"net/http"
+	"reflect"
 	"regexp"
+	"runtime"
 )
 
 // HandlerFunc the handler func of route
 type HandlerFunc func(*Context)
 
+// HandlerFuncE the handler func of route that returns error
+// 需经E转换为HandlerFunc后注册，返回的错误会终止执行后续handler，并由Server的ErrorMapper映射为响应
+type HandlerFuncE func(*Context) error
+
 // HandlerChain the set of handler func
 type HandlerChain []HandlerFunc
 
@@ -40,7 +46,7 @@ type router struct {
 // handle 注册路由和中间件
 func (r *router) handle(method, relativePath string, priority int, handlers HandlerChain) {
 	_, abspath, paramKeys := parseCleanPath(r.basePath, relativePath)
-	r.server.metas.add(method, abspath, priority, paramKeys, handlers)
+	r.server.metas.add(method, abspath, priority, paramKeys, handlers, handlerNames(handlers))
 }
 
 // handle 使用默认优先级注册路由
@@ -123,3 +129,60 @@ func (r *router) CONNECT(relativePath string, handlers ...HandlerFunc) {
 func (r *router) TRACE(relativePath string, handlers ...HandlerFunc) {
 	r.handleWithDefaultPriority(http.MethodTrace, relativePath, handlers)
 }
+
+// WrapH 将http.Handler转换为HandlerFunc
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(&c.responser, c.Request)
+	}
+}
+
+// WrapF 将http.HandlerFunc转换为HandlerFunc
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return WrapH(f)
+}
+
+// E 将返回error的handler转换为HandlerFunc
+// 返回的错误会终止执行后续handler，并由Server的ErrorMapper映射为响应
+//
+//	s.GET("/users/:id", nets.E(getUser))
+func E(fn HandlerFuncE) HandlerFunc {
+	return wrapHandlerFuncE(nameOfFunction(fn), fn)
+}
+
+// wrapHandlerFuncE 将HandlerFuncE转换为HandlerFunc，name为注册时记录的handler名称
+func wrapHandlerFuncE(name string, fn HandlerFuncE) HandlerFunc {
+	return func(c *Context) {
+		if c.nameProbe != nil {
+			*c.nameProbe = name
+			return
+		}
+		if err := fn(c); err != nil {
+			c.Abort()
+			c.Error(err)
+			c.handleErrors()
+		}
+	}
+}
+
+// wrappedHandlerName wrapHandlerFuncE返回的函数的名称
+var wrappedHandlerName = nameOfFunction(wrapHandlerFuncE("", nil))
+
+// handlerNames 返回各handler的名称，E转换的handler返回原函数的名称
+func handlerNames(handlers HandlerChain) []string {
+	names := make([]string, 0, len(handlers))
+	for _, handler := range handlers {
+		name := nameOfFunction(handler)
+		if name == wrappedHandlerName {
+			// 以探测context调用，handler只写入原函数名称而不执行
+			handler(&Context{nameProbe: &name})
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// nameOfFunction 返回函数名称，如main.getUser
+func nameOfFunction(fn interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestE(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  HandlerFuncE
+		status   int
+		body     string
+		handlers string
+	}{
+		{"nil error", func(c *Context) error { return nil }, http.StatusOK, "", "before,after"},
+		{"error", func(c *Context) error {
+			return errors.New("db down")
+		}, http.StatusInternalServerError, `{"code":500,"message":"Internal Server Error","data":{}}`, "before"},
+		{"typed error", func(c *Context) error {
+			return &Error{Err: errors.New("no user"), Type: ErrorTypePublic, Status: http.StatusNotFound}
+		}, http.StatusNotFound, `{"code":404,"message":"no user","data":{}}`, "before"},
+		{"written", func(c *Context) error {
+			c.JSON(http.StatusAccepted, Result{})
+			return errors.New("late")
+		}, http.StatusAccepted, `{"code":0,"message":"","data":{}}`, "before"},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.Use(mark("before"))
+		s.GET("/", E(tt.handler), mark("after"))
+		s.buildTrees()
+
+		w := do(s, httptest.NewRequest(http.MethodGet, "/", nil))
+		handlers := w.Header().Values("X-Handlers")
+		if w.Code != tt.status || w.Body.String() != tt.body || strings.Join(handlers, ",") != tt.handlers {
+			t.Errorf("%s: GET / = %d %s, handlers %v, want %d %s, %s", tt.name, w.Code, w.Body.String(), handlers, tt.status, tt.body, tt.handlers)
+		}
+	}
+}
+
+func TestEMiddleware(t *testing.T) {
+	s := New()
+	s.SetErrorMapper(func(c *Context, err *Error) (int, Result) {
+		return http.StatusUnauthorized, Result{Message: err.Error()}
+	})
+	s.Use(E(func(c *Context) error {
+		if c.Request.Header.Get("Authorization") == "" {
+			return errors.New("unauthorized")
+		}
+		c.Next()
+		return nil
+	}))
+	s.GET("/", mark("handler"))
+	s.buildTrees()
+
+	if code, handlers := serve(s, http.MethodGet, "/"); code != http.StatusUnauthorized || handlers != "" {
+		t.Errorf("GET / without auth = %d, handlers %s", code, handlers)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "token")
+	if w := do(s, req); w.Code != http.StatusOK || w.Header().Get("X-Handlers") != "handler" {
+		t.Errorf("GET / with auth = %d, handlers %s", w.Code, w.Header().Get("X-Handlers"))
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// sessionContextKey 会话在Context.Keys中的key
+	sessionContextKey string = "nets/session"
+	// sessionFlashKey 闪存消息在会话数据中的key
+	sessionFlashKey string = "_flash"
+	// 默认的会话cookie名称
+	defaultSessionCookieName string = "nets_session"
+	// 默认的会话有效期
+	defaultSessionMaxAge = 24 * time.Hour
+)
+
+// SessionStore 会话存储
+type SessionStore interface {
+	// Load 读取会话数据，会话不存在或已过期时返回nil, nil
+	Load(id string) (map[string]interface{}, error)
+	// Save 保存会话数据，ttl为会话有效期
+	Save(id string, values map[string]interface{}, ttl time.Duration) error
+	// Delete 删除会话
+	Delete(id string) error
+}
+
+// SessionOptions 会话中间件配置
+type SessionOptions struct {
+	// 会话cookie名称，为空时使用nets_session
+	CookieName string
+	// 会话有效期，小于等于0时使用24小时
+	MaxAge time.Duration
+}
+
+// Session 请求会话
+type Session struct {
+	id        string
+	oldID     string // Regenerate前的会话id
+	values    map[string]interface{}
+	isNew     bool
+	modified  bool
+	destroyed bool
+	mu        sync.RWMutex
+}
+
+// Sessions 返回会话中间件：按cookie中的会话id加载会话，在响应头写出前保存会话
+func Sessions(store SessionStore, options ...SessionOptions) HandlerFunc {
+	opts := SessionOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = defaultSessionCookieName
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultSessionMaxAge
+	}
+
+	return func(c *Context) {
+		session := loadSession(c, store, opts)
+		c.Set(sessionContextKey, session)
+
+		committed := false
+		commit := func() {
+			if !committed {
+				committed = true
+				saveSession(c, store, opts, session)
+			}
+		}
+		c.responser.before(commit)
+		c.Next()
+		commit()
+	}
+}
+
+// Session 返回当前请求的会话，未挂载Sessions中间件时返回nil
+func (c *Context) Session() *Session {
+	if value, ok := c.Get(sessionContextKey); ok {
+		if session, ok := value.(*Session); ok {
+			return session
+		}
+	}
+	return nil
+}
+
+// loadSession 按cookie中的会话id加载会话，不存在时创建新会话
+func loadSession(c *Context, store SessionStore, opts SessionOptions) *Session {
+	if id, ok := c.Cookie(opts.CookieName); ok && validSessionID(id) {
+		values, err := store.Load(id)
+		if err != nil {
+			debugPrintf("[ERROR] cannot load session: %v\n", err)
+			c.Error(err)
+		} else if values != nil {
+			return &Session{id: id, values: values}
+		}
+	}
+	return &Session{id: newSessionID(), values: make(map[string]interface{}), isNew: true}
+}
+
+// saveSession 持久化会话并设置会话cookie
+func saveSession(c *Context, store SessionStore, opts SessionOptions, session *Session) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if session.oldID != "" {
+		if err := store.Delete(session.oldID); err != nil {
+			debugPrintf("[ERROR] cannot delete session: %v\n", err)
+			c.Error(err)
+		}
+		session.oldID = ""
+	}
+
+	if session.destroyed {
+		if err := store.Delete(session.id); err != nil {
+			debugPrintf("[ERROR] cannot delete session: %v\n", err)
+			c.Error(err)
+		}
+		if !session.isNew {
+			c.DeleteCookie(opts.CookieName)
+		}
+		return
+	}
+
+	// 未修改的会话不落存储也不下发cookie，避免为每个访客创建会话
+	if !session.modified {
+		return
+	}
+
+	if err := store.Save(session.id, session.values, opts.MaxAge); err != nil {
+		debugPrintf("[ERROR] cannot save session: %v\n", err)
+		c.Error(err)
+		return
+	}
+	session.modified = false
+
+	if !c.responser.Written() {
+		c.SetCookie(opts.CookieName, session.id, int(opts.MaxAge/time.Second))
+	}
+}
+
+// ID 返回会话id
+func (s *Session) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// IsNew 是否为本次请求新建的会话
+func (s *Session) IsNew() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isNew
+}
+
+// Get 返回会话数据key的值，若key不存在，则第二个返回值为false
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Key 返回会话数据key的值
+func (s *Session) Key(key string, defaults ...interface{}) interface{} {
+	if value, ok := s.Get(key); ok {
+		return value
+	}
+	return IndexOf(defaults, 0, nil)
+}
+
+// Set 新增或更新会话数据
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	s.values[key] = value
+	s.modified = true
+	s.mu.Unlock()
+}
+
+// Delete 删除会话数据key
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	delete(s.values, key)
+	s.modified = true
+	s.mu.Unlock()
+}
+
+// Clear 清空会话数据
+func (s *Session) Clear() {
+	s.mu.Lock()
+	s.values = make(map[string]interface{})
+	s.modified = true
+	s.mu.Unlock()
+}
+
+// AddFlash 添加闪存消息，闪存消息被Flashes读取后即删除
+func (s *Session) AddFlash(value interface{}) {
+	s.mu.Lock()
+	flashes, _ := s.values[sessionFlashKey].([]interface{})
+	s.values[sessionFlashKey] = append(flashes, value)
+	s.modified = true
+	s.mu.Unlock()
+}
+
+// Flashes 返回并删除全部闪存消息
+func (s *Session) Flashes() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes, ok := s.values[sessionFlashKey].([]interface{})
+	if ok {
+		delete(s.values, sessionFlashKey)
+		s.modified = true
+	}
+	return flashes
+}
+
+// Regenerate 更换会话id并保留会话数据，权限变更（如登录）时调用以防止会话固定攻击
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	if !s.isNew && s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = newSessionID()
+	s.modified = true
+	s.mu.Unlock()
+}
+
+// Destroy 销毁会话，删除存储中的会话数据和会话cookie
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	s.values = make(map[string]interface{})
+	s.destroyed = true
+	s.mu.Unlock()
+}
+
+// newSessionID 生成随机会话id
+func newSessionID() string {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		panic("nets: cannot generate session id: " + err.Error())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// validSessionID 会话id是否为newSessionID生成的格式
+func validSessionID(id string) bool {
+	if len(id) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// 会话文件名前缀
+	sessionFilePrefix string = "nets_sess_"
+)
+
+func init() {
+	// 会话闪存消息以[]interface{}保存
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// memorySession 内存会话条目
+type memorySession struct {
+	values  map[string]interface{}
+	expires time.Time
+}
+
+// MemorySessionStore 内存会话存储，会话过期后惰性删除，超出容量时淘汰最早过期的会话
+type MemorySessionStore struct {
+	sessions   map[string]memorySession
+	maxEntries int
+	mu         sync.Mutex
+}
+
+// NewMemorySessionStore return new *MemorySessionStore
+// maxEntries <= 0 时不限制会话数量
+func NewMemorySessionStore(maxEntries int) *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession), maxEntries: maxEntries}
+}
+
+// Load 实现SessionStore接口
+func (store *MemorySessionStore) Load(id string) (map[string]interface{}, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	session, ok := store.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(session.expires) {
+		delete(store.sessions, id)
+		return nil, nil
+	}
+	return copySessionValues(session.values), nil
+}
+
+// Save 实现SessionStore接口
+func (store *MemorySessionStore) Save(id string, values map[string]interface{}, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.sessions[id]; !ok && store.maxEntries > 0 && len(store.sessions) >= store.maxEntries {
+		store.evict()
+	}
+	store.sessions[id] = memorySession{values: copySessionValues(values), expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Delete 实现SessionStore接口
+func (store *MemorySessionStore) Delete(id string) error {
+	store.mu.Lock()
+	delete(store.sessions, id)
+	store.mu.Unlock()
+	return nil
+}
+
+// Len 返回当前会话数量（包含已过期未删除的会话）
+func (store *MemorySessionStore) Len() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return len(store.sessions)
+}
+
+// evict 删除全部过期会话，仍超出容量时淘汰最早过期的会话，调用方需持有锁
+func (store *MemorySessionStore) evict() {
+	now, oldestID, oldest := time.Now(), "", time.Time{}
+	for id, session := range store.sessions {
+		if now.After(session.expires) {
+			delete(store.sessions, id)
+			continue
+		}
+		if oldestID == "" || session.expires.Before(oldest) {
+			oldestID, oldest = id, session.expires
+		}
+	}
+	if len(store.sessions) >= store.maxEntries && oldestID != "" {
+		delete(store.sessions, oldestID)
+	}
+}
+
+// fileSession 文件会话内容
+type fileSession struct {
+	Values  map[string]interface{}
+	Expires time.Time
+}
+
+// FileSessionStore 文件会话存储，每个会话保存为dir下的一个gob文件
+// 会话数据中的自定义类型需先通过gob.Register注册
+type FileSessionStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewFileSessionStore return new *FileSessionStore，dir不存在时自动创建
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// Load 实现SessionStore接口
+func (store *FileSessionStore) Load(id string) (map[string]interface{}, error) {
+	if !validSessionID(id) {
+		return nil, nil
+	}
+
+	store.mu.RLock()
+	data, err := ioutil.ReadFile(store.filename(id))
+	store.mu.RUnlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	session := fileSession{}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&session); err != nil {
+		return nil, err
+	}
+	if time.Now().After(session.Expires) {
+		return nil, store.Delete(id)
+	}
+	if session.Values == nil {
+		session.Values = make(map[string]interface{})
+	}
+	return session.Values, nil
+}
+
+// Save 实现SessionStore接口
+func (store *FileSessionStore) Save(id string, values map[string]interface{}, ttl time.Duration) error {
+	if !validSessionID(id) {
+		return errors.New("nets: invalid session id")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(fileSession{Values: values, Expires: time.Now().Add(ttl)}); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	// 先写临时文件再重命名，避免读到写了一半的会话文件
+	tmp := store.filename(id) + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, store.filename(id))
+}
+
+// Delete 实现SessionStore接口
+func (store *FileSessionStore) Delete(id string) error {
+	if !validSessionID(id) {
+		return nil
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if err := os.Remove(store.filename(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// GC 删除全部已过期的会话文件
+func (store *FileSessionStore) GC() error {
+	files, err := filepath.Glob(filepath.Join(store.dir, sessionFilePrefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		id := filepath.Base(file)[len(sessionFilePrefix):]
+		if _, err := store.Load(id); err != nil {
+			debugPrintf("[ERROR] cannot load session file %s: %v\n", file, err)
+		}
+	}
+	return nil
+}
+
+// filename 返回会话文件路径
+func (store *FileSessionStore) filename(id string) string {
+	return filepath.Join(store.dir, sessionFilePrefix+id)
+}
+
+// copySessionValues 浅拷贝会话数据
+func copySessionValues(values map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sessionServer 返回挂载了Sessions中间件的Server，响应头X-Value为会话数据user或闪存消息
+func sessionServer(store SessionStore) *Server {
+	s := New()
+	s.Use(Sessions(store, SessionOptions{CookieName: "sid", MaxAge: time.Hour}))
+	value := func(c *Context, value interface{}) {
+		c.responser.Header().Set("X-Value", fmt.Sprint(value))
+		c.JSON(http.StatusOK, Result{})
+	}
+	s.GET("/get", func(c *Context) { value(c, c.Session().Key("user", "")) })
+	s.GET("/set", func(c *Context) {
+		c.Session().Set("user", "nets")
+		value(c, "")
+	})
+	s.GET("/login", func(c *Context) {
+		c.Session().Regenerate()
+		value(c, c.Session().Key("user", ""))
+	})
+	s.GET("/flash", func(c *Context) {
+		c.Session().AddFlash("hi")
+		value(c, "")
+	})
+	s.GET("/flashes", func(c *Context) { value(c, c.Session().Flashes()) })
+	s.GET("/logout", func(c *Context) {
+		c.Session().Destroy()
+		value(c, "")
+	})
+	s.buildTrees()
+	return s
+}
+
+func TestSessions(t *testing.T) {
+	fileStore, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileSessionStore = %v", err)
+	}
+	stores := map[string]SessionStore{"memory": NewMemorySessionStore(0), "file": fileStore}
+
+	// cookie: none 未下发cookie，new 下发了新的会话id，refreshed 修改会话后刷新有效期，deleted 删除cookie
+	steps := []struct {
+		path   string
+		value  string
+		cookie string
+	}{
+		{"/get", "", "none"},
+		{"/set", "", "new"},
+		{"/get", "nets", "none"},
+		{"/login", "nets", "new"},
+		{"/get", "nets", "none"},
+		{"/flash", "", "refreshed"},
+		{"/flashes", "[hi]", "refreshed"},
+		{"/flashes", "[]", "none"},
+		{"/logout", "", "deleted"},
+		{"/get", "", "none"},
+	}
+	for name, store := range stores {
+		s := sessionServer(store)
+		id := ""
+		for i, step := range steps {
+			req := httptest.NewRequest(http.MethodGet, step.path, nil)
+			if id != "" {
+				req.AddCookie(&http.Cookie{Name: "sid", Value: id})
+			}
+			w := do(s, req)
+
+			cookie := "none"
+			for _, c := range w.Result().Cookies() {
+				switch {
+				case c.MaxAge < 0:
+					cookie = "deleted"
+				case c.Value == id && c.MaxAge == 3600:
+					cookie = "refreshed"
+				case c.Value != id && validSessionID(c.Value) && c.MaxAge == 3600:
+					cookie, id = "new", c.Value
+				default:
+					cookie = "unexpected " + c.String()
+				}
+			}
+			if got := w.Header().Get("X-Value"); got != step.value || cookie != step.cookie {
+				t.Errorf("%s step %d %s: value %q, cookie %s, want %q, %s", name, i, step.path, got, cookie, step.value, step.cookie)
+			}
+		}
+	}
+}
+
+func TestSessionRegenerateDeletesOldID(t *testing.T) {
+	store := NewMemorySessionStore(0)
+	s := sessionServer(store)
+
+	w := do(s, httptest.NewRequest(http.MethodGet, "/set", nil))
+	oldID := w.Result().Cookies()[0].Value
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	req.AddCookie(&http.Cookie{Name: "sid", Value: oldID})
+	w = do(s, req)
+	newID := w.Result().Cookies()[0].Value
+
+	if values, _ := store.Load(oldID); values != nil {
+		t.Errorf("old session %s still loadable: %v", oldID, values)
+	}
+	if values, _ := store.Load(newID); values["user"] != "nets" {
+		t.Errorf("new session values = %v", values)
+	}
+	if store.Len() != 1 {
+		t.Errorf("store Len = %d, want 1", store.Len())
+	}
+}
+
+func TestSessionInvalidCookie(t *testing.T) {
+	store := NewMemorySessionStore(0)
+	s := sessionServer(store)
+	for _, id := range []string{"../../etc/passwd", "short", newSessionID()} {
+		req := httptest.NewRequest(http.MethodGet, "/get", nil)
+		req.AddCookie(&http.Cookie{Name: "sid", Value: id})
+		if w := do(s, req); w.Header().Get("X-Value") != "" || len(w.Result().Cookies()) != 0 {
+			t.Errorf("cookie %q: value %q, cookies %v", id, w.Header().Get("X-Value"), w.Result().Cookies())
+		}
+	}
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	store := NewMemorySessionStore(2)
+	ids := []string{newSessionID(), newSessionID(), newSessionID()}
+	store.Save(ids[0], map[string]interface{}{"n": 0}, time.Hour)
+	store.Save(ids[1], map[string]interface{}{"n": 1}, 2*time.Hour)
+	store.Save(ids[2], map[string]interface{}{"n": 2}, 3*time.Hour)
+
+	if values, _ := store.Load(ids[0]); values != nil || store.Len() != 2 {
+		t.Errorf("earliest expiring session not evicted: %v, Len %d", values, store.Len())
+	}
+
+	values, _ := store.Load(ids[1])
+	values["n"] = 10
+	if values, _ = store.Load(ids[1]); values["n"] != 1 {
+		t.Errorf("Load returned shared values: %v", values)
+	}
+
+	store.Save(ids[2], map[string]interface{}{"n": 2}, -time.Second)
+	if values, _ = store.Load(ids[2]); values != nil || store.Len() != 1 {
+		t.Errorf("expired session loaded: %v, Len %d", values, store.Len())
+	}
+	store.Delete(ids[1])
+	if store.Len() != 0 {
+		t.Errorf("Len after Delete = %d, want 0", store.Len())
+	}
+}
+
+func TestFileSessionStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(filepath.Join(dir, "sessions"))
+	if err != nil {
+		t.Fatalf("NewFileSessionStore = %v", err)
+	}
+
+	id, expired := newSessionID(), newSessionID()
+	values := map[string]interface{}{"user": "nets", sessionFlashKey: []interface{}{"hi"}}
+	if err = store.Save(id, values, time.Hour); err != nil {
+		t.Fatalf("Save = %v", err)
+	}
+	if err = store.Save(expired, values, -time.Second); err != nil {
+		t.Fatalf("Save = %v", err)
+	}
+	if err = store.Save("../bad", values, time.Hour); err == nil {
+		t.Error("Save with invalid id succeeded")
+	}
+
+	loaded, err := store.Load(id)
+	if err != nil || loaded["user"] != "nets" || fmt.Sprint(loaded[sessionFlashKey]) != "[hi]" {
+		t.Errorf("Load = %v, %v", loaded, err)
+	}
+	if loaded, err = store.Load("../bad"); loaded != nil || err != nil {
+		t.Errorf("Load invalid id = %v, %v", loaded, err)
+	}
+
+	if err = store.GC(); err != nil {
+		t.Fatalf("GC = %v", err)
+	}
+	if _, err = os.Stat(store.filename(expired)); !os.IsNotExist(err) {
+		t.Errorf("expired session file not removed: %v", err)
+	}
+	if _, err = os.Stat(store.filename(id)); err != nil {
+		t.Errorf("live session file removed: %v", err)
+	}
+
+	store.Delete(id)
+	if loaded, err = store.Load(id); loaded != nil || err != nil {
+		t.Errorf("Load after Delete = %v, %v", loaded, err)
+	}
+}
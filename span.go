@@ -0,0 +1,327 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderTraceParent W3C trace-context traceparent请求头
+	HeaderTraceParent string = "traceparent"
+	// HeaderTraceState W3C trace-context tracestate请求头
+	HeaderTraceState string = "tracestate"
+
+	// traceparent版本
+	traceParentVersion string = "00"
+	// trace flags sampled位
+	traceFlagSampled byte = 0x01
+)
+
+// SpanKind span类型（与OTLP定义一致）
+type SpanKind int
+
+const (
+	// SpanKindInternal 内部span
+	SpanKindInternal SpanKind = 1
+	// SpanKindServer 服务端span
+	SpanKindServer SpanKind = 2
+	// SpanKindClient 客户端span
+	SpanKindClient SpanKind = 3
+)
+
+// Span 请求处理过程中的一段耗时操作
+type Span struct {
+	TraceID    string         // trace id（32位十六进制）
+	SpanID     string         // span id（16位十六进制）
+	ParentID   string         // 父span id
+	Name       string         // span名称
+	Kind       SpanKind       // span类型
+	StartTime  time.Time      // 开始时间
+	EndTime    time.Time      // 结束时间
+	Attributes map[string]Any // 属性
+	Error      string         // 错误信息
+
+	ctx   *Context
+	gen   uint64 // 开始时Context的spanGen
+	mu    sync.Mutex
+	ended bool
+}
+
+// SpanExporter span导出器
+type SpanExporter interface {
+	// ExportSpans 导出一个请求的全部span，第一个为服务端span
+	ExportSpans(spans []*Span) error
+}
+
+// initTraceContext 解析traceparent/tracestate请求头，生成服务端span id
+// traceparent不存在或不合法时生成新的trace id
+func (c *Context) initTraceContext() {
+	traceID, parentID, flags, ok := parseTraceParent(c.Request.Header.Get(HeaderTraceParent))
+	if ok {
+		c.Trace.ParentID = parentID
+		c.Trace.TraceState = c.Request.Header.Get(HeaderTraceState)
+		value, _ := strconv.ParseUint(flags, 16, 8)
+		c.Trace.Sampled = byte(value)&traceFlagSampled != 0
+	} else {
+		traceID = newTraceID()
+		c.Trace.Sampled = sampleTraceID(traceID, c.server.Config.traceSampleRatio)
+		flags = "00"
+		if c.Trace.Sampled {
+			flags = "01"
+		}
+	}
+	c.Trace.TraceID = traceID
+	c.Trace.SpanID = newSpanID()
+	c.Trace.TraceFlags = flags
+}
+
+// TraceParent 返回用于向下游传播的traceparent，父span为服务端span
+// 未开启trace时返回空字符串
+func (c *Context) TraceParent() string {
+	c.guard()
+	if c.Trace.TraceID == "" {
+		return ""
+	}
+	return traceParentVersion + "-" + c.Trace.TraceID + "-" + c.Trace.SpanID + "-" + c.Trace.TraceFlags
+}
+
+// InjectTraceContext 将traceparent和tracestate写入下游请求头
+func (c *Context) InjectTraceContext(header http.Header) {
+	if traceParent := c.TraceParent(); traceParent != "" {
+		header.Set(HeaderTraceParent, traceParent)
+		if c.Trace.TraceState != "" {
+			header.Set(HeaderTraceState, c.Trace.TraceState)
+		}
+	}
+}
+
+// StartSpan 开始一个服务端span的子span，需调用End结束
+// 未开启trace时返回的span不会被记录
+func (c *Context) StartSpan(name string) *Span {
+	c.guard()
+	return c.startSpan(name, c.Trace.SpanID)
+}
+
+// WithSpan 返回包裹handler的中间件，handler（含其调用的后续handler）的执行过程记录为一个span
+func WithSpan(name string, handler HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		span := c.StartSpan(name)
+		defer span.End()
+		handler(c)
+	}
+}
+
+// startSpan 开始一个span
+func (c *Context) startSpan(name, parentID string) *Span {
+	span := &Span{
+		TraceID:   c.Trace.TraceID,
+		ParentID:  parentID,
+		Name:      name,
+		Kind:      SpanKindInternal,
+		StartTime: time.Now(),
+	}
+	if c.server.Config.trace && c.Trace.TraceID != "" {
+		span.SpanID = newSpanID()
+		span.ctx = c
+		c.spansMu.Lock()
+		span.gen = c.spanGen
+		c.spansMu.Unlock()
+	}
+	return span
+}
+
+// StartChild 开始一个子span
+func (s *Span) StartChild(name string) *Span {
+	if s.ctx == nil {
+		return &Span{Name: name, StartTime: time.Now()}
+	}
+	return s.ctx.startSpan(name, s.SpanID)
+}
+
+// SetAttribute 设置span属性
+func (s *Span) SetAttribute(key string, value Any) *Span {
+	s.mu.Lock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]Any)
+	}
+	s.Attributes[key] = value
+	s.mu.Unlock()
+	return s
+}
+
+// SetError 记录span错误
+func (s *Span) SetError(err error) *Span {
+	if err != nil {
+		s.mu.Lock()
+		s.Error = err.Error()
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// End 结束span并记录到Context.Trace.Spans，重复调用无效
+// trace输出后（handler返回后）才结束的span不再记录
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	if c := s.ctx; c != nil {
+		c.spansMu.Lock()
+		if s.gen == c.spanGen {
+			c.Trace.Spans = append(c.Trace.Spans, s)
+		}
+		c.spansMu.Unlock()
+	}
+}
+
+// snapshot 返回span的副本，副本不再关联Context
+func (s *Span) snapshot() *Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := &Span{
+		TraceID:   s.TraceID,
+		SpanID:    s.SpanID,
+		ParentID:  s.ParentID,
+		Name:      s.Name,
+		Kind:      s.Kind,
+		StartTime: s.StartTime,
+		EndTime:   s.EndTime,
+		Error:     s.Error,
+		ended:     s.ended,
+	}
+	if s.Attributes != nil {
+		cp.Attributes = make(map[string]Any, len(s.Attributes))
+		for k, v := range s.Attributes {
+			cp.Attributes[k] = v
+		}
+	}
+	return cp
+}
+
+// closeSpans 固定Context.Trace.Spans，之后结束的span不再记录，在trace输出前调用
+func (c *Context) closeSpans() {
+	c.spansMu.Lock()
+	c.spanGen++
+	c.spansMu.Unlock()
+}
+
+// serverSpan 根据trace构造服务端span
+func (c *Context) serverSpan() *Span {
+	span := &Span{
+		TraceID:   c.Trace.TraceID,
+		SpanID:    c.Trace.SpanID,
+		ParentID:  c.Trace.ParentID,
+		Name:      c.Request.Method,
+		Kind:      SpanKindServer,
+		StartTime: c.Trace.StartTime,
+		EndTime:   c.Trace.EndTime,
+		Attributes: map[string]Any{
+			"http.method":      c.Request.Method,
+			"http.target":      c.Request.URL.RequestURI(),
+			"http.status_code": c.Trace.Status,
+			"http.client_ip":   c.Trace.ClientIP,
+		},
+		ended: true,
+	}
+	if c.Trace.Route != "" {
+		span.Name += " " + c.Trace.Route
+		span.Attributes["http.route"] = c.Trace.Route
+	}
+	if len(c.Trace.Errors) > 0 {
+		span.Error = strings.Join(c.Trace.Errors, "; ")
+	} else if c.Trace.Status >= http.StatusInternalServerError {
+		span.Error = http.StatusText(c.Trace.Status)
+	}
+	return span
+}
+
+// ExportSpans 返回导出span的trace handler，使用Server.Trace注册：s.Trace(nets.ExportSpans(exporter))
+// 导出的第一个span为服务端span，其余为请求处理过程中结束的子span；未被采样的请求不导出
+func ExportSpans(exporter SpanExporter) HandlerFunc {
+	return func(c *Context) {
+		if c.Trace.TraceID == "" || !c.Trace.Sampled {
+			return
+		}
+
+		c.spansMu.Lock()
+		spans := make([]*Span, 0, len(c.Trace.Spans)+1)
+		spans = append(spans, c.serverSpan())
+		spans = append(spans, c.Trace.Spans...)
+		c.spansMu.Unlock()
+
+		if err := exporter.ExportSpans(spans); err != nil {
+			debugPrintf("[ERROR] cannot export spans: %v\n", err)
+		}
+	}
+}
+
+// parseTraceParent 解析traceparent：version-traceid-parentid-flags
+func parseTraceParent(value string) (traceID, parentID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return
+	}
+
+	version := parts[0]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" || (version == traceParentVersion && len(parts) != 4) {
+		return
+	}
+
+	traceID, parentID, flags = parts[1], parts[2], parts[3]
+	if len(traceID) != 32 || !isLowerHex(traceID) || strings.Count(traceID, "0") == 32 {
+		return
+	}
+	if len(parentID) != 16 || !isLowerHex(parentID) || strings.Count(parentID, "0") == 16 {
+		return
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return
+	}
+
+	ok = true
+	return
+}
+
+// isLowerHex 是否为小写十六进制字符串
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newTraceID 生成随机trace id
+func newTraceID() string {
+	return randomHex(16)
+}
+
+// newSpanID 生成随机span id
+func newSpanID() string {
+	return randomHex(8)
+}
+
+// randomHex 生成n字节随机数的十六进制字符串
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		panic("nets: cannot generate random id: " + err.Error())
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,205 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testTraceID  = "0af7651916cd43dd8448eb211c80319c"
+	testParentID = "b7ad6b7169203331"
+)
+
+// memExporter 在内存中收集span的SpanExporter
+type memExporter struct {
+	mu      sync.Mutex
+	exports [][]*Span
+}
+
+func (exporter *memExporter) ExportSpans(spans []*Span) error {
+	exporter.mu.Lock()
+	exporter.exports = append(exporter.exports, spans)
+	exporter.mu.Unlock()
+	return nil
+}
+
+// last 返回最近一次导出的span名称
+func (exporter *memExporter) last() []string {
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	if len(exporter.exports) == 0 {
+		return nil
+	}
+	var names []string
+	for _, span := range exporter.exports[len(exporter.exports)-1] {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"00-" + testTraceID + "-" + testParentID + "-01", true},
+		{" 00-" + testTraceID + "-" + testParentID + "-00 ", true},
+		{"01-" + testTraceID + "-" + testParentID + "-01-future", true},
+		{"ff-" + testTraceID + "-" + testParentID + "-01", false},
+		{"00-" + testTraceID + "-" + testParentID + "-01-extra", false},
+		{"00-" + strings.Repeat("0", 32) + "-" + testParentID + "-01", false},
+		{"00-" + testTraceID + "-" + strings.Repeat("0", 16) + "-01", false},
+		{"00-" + strings.ToUpper(testTraceID) + "-" + testParentID + "-01", false},
+		{"00-" + testTraceID + "-" + strings.ToUpper(testParentID) + "-01", false},
+		{"0A-" + testTraceID + "-" + testParentID + "-01", false},
+		{"00-" + testTraceID + "-" + testParentID + "-0g", false},
+		{"00-" + testTraceID[1:] + "-" + testParentID + "-01", false},
+		{"00-" + testTraceID + "-" + testParentID, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		traceID, parentID, flags, ok := parseTraceParent(tt.value)
+		if ok != tt.ok {
+			t.Errorf("parseTraceParent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			continue
+		}
+		if ok && (traceID != testTraceID || parentID != testParentID || len(flags) != 2) {
+			t.Errorf("parseTraceParent(%q) = %s, %s, %s", tt.value, traceID, parentID, flags)
+		}
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		traceState  string
+		continued   bool
+	}{
+		{"00-" + testTraceID + "-" + testParentID + "-01", "nets=1", true},
+		{"00-" + strings.ToUpper(testTraceID) + "-" + testParentID + "-01", "nets=1", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.Trace(func(c *Context) {})
+		var traceParent, parentID string
+		downstream := make(http.Header)
+		s.GET("/", func(c *Context) {
+			traceParent, parentID = c.TraceParent(), c.Trace.ParentID
+			c.InjectTraceContext(downstream)
+		})
+		s.buildTrees()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderTraceParent, tt.traceParent)
+		req.Header.Set(HeaderTraceState, tt.traceState)
+		do(s, req)
+
+		traceID, spanID, flags, ok := parseTraceParent(traceParent)
+		if !ok || flags != "01" || spanID == testParentID {
+			t.Errorf("traceparent %q: TraceParent = %q", tt.traceParent, traceParent)
+		}
+		if tt.continued != (traceID == testTraceID && parentID == testParentID) {
+			t.Errorf("traceparent %q: trace %s, parent %q, continued want %v", tt.traceParent, traceID, parentID, tt.continued)
+		}
+		if downstream.Get(HeaderTraceParent) != traceParent {
+			t.Errorf("traceparent %q: injected %q, want %q", tt.traceParent, downstream.Get(HeaderTraceParent), traceParent)
+		}
+		if tt.continued && downstream.Get(HeaderTraceState) != tt.traceState {
+			t.Errorf("traceparent %q: injected tracestate %q, want %q", tt.traceParent, downstream.Get(HeaderTraceState), tt.traceState)
+		}
+	}
+}
+
+func TestSpanRecording(t *testing.T) {
+	exporter := &memExporter{}
+	s := New()
+	s.Trace(ExportSpans(exporter))
+	s.GET("/users/:id", WithSpan("middleware", func(c *Context) { c.Next() }), func(c *Context) {
+		span := c.StartSpan("db")
+		child := span.StartChild("query").SetAttribute("rows", 3)
+		child.End()
+		span.SetError(errors.New("timeout")).End()
+		span.End()
+	})
+	s.buildTrees()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(HeaderTraceParent, "00-"+testTraceID+"-"+testParentID+"-01")
+	do(s, req)
+
+	if got := strings.Join(exporter.last(), ","); got != "GET /users/:id,query,db,middleware" {
+		t.Fatalf("exported spans = %s", got)
+	}
+	spans := exporter.exports[0]
+	server, query, db, middleware := spans[0], spans[1], spans[2], spans[3]
+	if server.Kind != SpanKindServer || server.ParentID != testParentID || server.Attributes["http.route"] != "/users/:id" {
+		t.Errorf("server span = %+v", server)
+	}
+	for _, span := range spans {
+		if span.TraceID != testTraceID || span.EndTime.Before(span.StartTime) {
+			t.Errorf("span %s: trace %s, start %v, end %v", span.Name, span.TraceID, span.StartTime, span.EndTime)
+		}
+	}
+	if db.ParentID != server.SpanID || middleware.ParentID != server.SpanID || query.ParentID != db.SpanID {
+		t.Errorf("span parents: db %s, middleware %s, query %s", db.ParentID, middleware.ParentID, query.ParentID)
+	}
+	if db.Error != "timeout" || query.Attributes["rows"] != 3 || query.Kind != SpanKindInternal {
+		t.Errorf("db error %q, query attributes %v", db.Error, query.Attributes)
+	}
+}
+
+func TestSpanWithoutTrace(t *testing.T) {
+	s := New()
+	var span *Span
+	var traceParent string
+	s.GET("/", func(c *Context) {
+		span = c.StartSpan("db")
+		span.StartChild("query").End()
+		span.End()
+		traceParent = c.TraceParent()
+	})
+	s.buildTrees()
+	serve(s, http.MethodGet, "/")
+
+	if span.SpanID != "" || traceParent != "" {
+		t.Errorf("span id %q, traceparent %q, want empty without trace", span.SpanID, traceParent)
+	}
+}
+
+func TestLateSpanDropped(t *testing.T) {
+	exporter := &memExporter{}
+	s := New()
+	s.Trace(ExportSpans(exporter))
+	var late *Span
+	s.GET("/first", func(c *Context) {
+		c.StartSpan("early").End()
+		late = c.StartSpan("late")
+	})
+	s.GET("/second", func(c *Context) {
+		late.End()
+		c.StartSpan("second").End()
+	})
+	s.buildTrees()
+
+	serve(s, http.MethodGet, "/first")
+	if got := strings.Join(exporter.last(), ","); got != "GET /first,early" {
+		t.Errorf("first exported spans = %s", got)
+	}
+	// late在第一个请求的trace输出后结束，即使Context被复用也不会记录到第二个请求
+	serve(s, http.MethodGet, "/second")
+	if got := strings.Join(exporter.last(), ","); got != "GET /second,second" {
+		t.Errorf("second exported spans = %s", got)
+	}
+	if len(exporter.exports[0]) != 2 {
+		t.Errorf("first export changed after late End: %d spans", len(exporter.exports[0]))
+	}
+}
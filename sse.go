@@ -0,0 +1,228 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ContentTypeEventStream sse Content-Type
+	ContentTypeEventStream string = "text/event-stream; charset=UTF-8"
+
+	// 默认心跳间隔
+	defaultSSEHeartbeatInterval = 15 * time.Second
+)
+
+// SSEvent server-sent event
+type SSEvent struct {
+	Event string // 事件名称
+	ID    string // 事件id，客户端重连时通过Last-Event-ID请求头带回
+	Retry int    // 客户端重连间隔（毫秒），小于等于0时不输出
+	Data  Any    // 事件数据，string和[]byte原样输出，其他类型使用JSONCodec序列化
+}
+
+// SSEWriter server-sent events writer
+type SSEWriter struct {
+	ctx          *Context
+	mu           sync.Mutex
+	heartbeatErr chan error // 心跳写入失败的错误，由handler所在goroutine读取
+}
+
+// SSE 设置event-stream响应头并返回SSEWriter
+func (c *Context) SSE() *SSEWriter {
+	c.guard()
+	header := c.responser.Header()
+	header.Set("Content-Type", ContentTypeEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.responser.WriteHeader(http.StatusOK)
+	c.flush()
+	if c.server.Config.trace {
+		c.Trace.Status = http.StatusOK
+	}
+	return &SSEWriter{ctx: c, heartbeatErr: make(chan error, 1)}
+}
+
+// Stream 循环调用step向客户端输出数据，直到step返回false或客户端断开连接
+// 客户端断开连接时返回true
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	c.guard()
+	done := c.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(&c.responser)
+			c.flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+// flush 刷新响应数据到客户端，ResponseWriter不支持http.Flusher时仅写入响应头
+func (c *Context) flush() {
+	if _, ok := c.responser.ResponseWriter.(http.Flusher); ok {
+		c.responser.Flush()
+		return
+	}
+	c.responser.WriteHeaderNow()
+}
+
+// LastEventID 返回客户端重连时带回的Last-Event-ID
+func (w *SSEWriter) LastEventID() string {
+	return w.ctx.Request.Header.Get("Last-Event-ID")
+}
+
+// Done 客户端断开连接时关闭的channel
+func (w *SSEWriter) Done() <-chan struct{} {
+	return w.ctx.Request.Context().Done()
+}
+
+// Send 输出一个事件并刷新到客户端
+func (w *SSEWriter) Send(event SSEvent) error {
+	buf := new(bytes.Buffer)
+	if event.Event != "" {
+		writeSSEField(buf, "event", event.Event)
+	}
+	if event.ID != "" {
+		writeSSEField(buf, "id", event.ID)
+	}
+	if event.Retry > 0 {
+		writeSSEField(buf, "retry", strconv.Itoa(event.Retry))
+	}
+
+	var data string
+	switch v := event.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		bytes, err := w.ctx.server.json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(bytes)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		writeSSEField(buf, "data", line)
+	}
+	buf.WriteByte('\n')
+
+	return w.write(buf.Bytes())
+}
+
+// Comment 输出注释行，客户端会忽略，通常用作心跳
+func (w *SSEWriter) Comment(text string) error {
+	buf := new(bytes.Buffer)
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return w.write(buf.Bytes())
+}
+
+// Heartbeat 每隔interval输出一次心跳注释，直到调用返回的stop函数、客户端断开连接或handler返回
+// 心跳写入失败时停止，错误通过HeartbeatErr返回，未被读取时在stop时记录到Context.Errors；interval小于等于0时使用默认的15s
+func (w *SSEWriter) Heartbeat(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = defaultSSEHeartbeatInterval
+	}
+	ticker, quit, exited := time.NewTicker(interval), make(chan struct{}), make(chan struct{})
+	data, done := []byte(": heartbeat\n\n"), w.Done()
+	go func() {
+		defer close(exited)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				// 心跳goroutine不访问Context的其他字段，错误交由handler所在goroutine处理
+				if err := w.send(data); err != nil {
+					select {
+					case w.heartbeatErr <- err:
+					default:
+					}
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() { close(quit) })
+		<-exited
+		w.drain()
+	}
+	w.ctx.heartbeats = append(w.ctx.heartbeats, stop)
+	return stop
+}
+
+// HeartbeatErr 心跳写入失败时收到错误的channel，可在handler中与Done一起select以结束推送
+func (w *SSEWriter) HeartbeatErr() <-chan error {
+	return w.heartbeatErr
+}
+
+// write 记录未读取的心跳错误，写入数据并刷新，写入失败时将错误记录到Context.Errors
+func (w *SSEWriter) write(data []byte) error {
+	w.drain()
+	if err := w.send(data); err != nil {
+		w.ctx.Error(err).SetType(ErrorTypeRender)
+		return err
+	}
+	return nil
+}
+
+// send 写入数据并刷新，可并发调用
+func (w *SSEWriter) send(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.ctx.responser.Write(data); err != nil {
+		return err
+	}
+	w.ctx.flush()
+	return nil
+}
+
+// drain 将未读取的心跳错误记录到Context.Errors，只能在handler所在goroutine调用
+func (w *SSEWriter) drain() {
+	select {
+	case err := <-w.heartbeatErr:
+		w.ctx.Error(err).SetType(ErrorTypeRender)
+	default:
+	}
+}
+
+// stopHeartbeats 停止handler未停止的心跳，在handler返回后调用
+func (c *Context) stopHeartbeats() {
+	for _, stop := range c.heartbeats {
+		stop()
+	}
+	c.heartbeats = nil
+}
+
+// writeSSEField 写入一个sse字段行
+func writeSSEField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(strings.NewReplacer("\r", "", "\n", "").Replace(value))
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSESend(t *testing.T) {
+	tests := []struct {
+		event SSEvent
+		want  string
+	}{
+		{SSEvent{Data: "hello"}, "data: hello\n\n"},
+		{SSEvent{Event: "update", ID: "7", Retry: 3000, Data: "x"}, "event: update\nid: 7\nretry: 3000\ndata: x\n\n"},
+		{SSEvent{Data: "a\nb"}, "data: a\ndata: b\n\n"},
+		{SSEvent{Data: []byte("raw")}, "data: raw\n\n"},
+		{SSEvent{Data: AnyMap{"n": 1}}, "data: {\"n\":1}\n\n"},
+		{SSEvent{Event: "evil\nevent: x", Data: nil}, "event: evilevent: x\ndata: \n\n"},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.GET("/events", func(c *Context) {
+			c.SSE().Send(tt.event)
+		})
+		s.buildTrees()
+
+		w := do(s, httptest.NewRequest(http.MethodGet, "/events", nil))
+		if w.Header().Get("Content-Type") != ContentTypeEventStream || w.Body.String() != tt.want {
+			t.Errorf("Send(%+v) = %q %q, want %q", tt.event, w.Header().Get("Content-Type"), w.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestSSEComment(t *testing.T) {
+	s := New()
+	var lastEventID string
+	s.GET("/events", func(c *Context) {
+		w := c.SSE()
+		lastEventID = w.LastEventID()
+		w.Comment("ping\npong")
+	})
+	s.buildTrees()
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "42")
+	w := do(s, req)
+	if got, want := w.Body.String(), ": ping\n: pong\n\n"; got != want || lastEventID != "42" {
+		t.Errorf("Comment = %q, LastEventID = %q, want %q, 42", got, lastEventID, want)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		wait     time.Duration
+		stop     bool
+		min      int
+	}{
+		{5 * time.Millisecond, 30 * time.Millisecond, true, 1},
+		{5 * time.Millisecond, 30 * time.Millisecond, false, 1},
+		{0, 0, true, 0},
+		{-time.Second, 0, false, 0},
+	}
+	for _, tt := range tests {
+		s := New()
+		s.GET("/events", func(c *Context) {
+			stop := c.SSE().Heartbeat(tt.interval)
+			time.Sleep(tt.wait)
+			if tt.stop {
+				stop()
+			}
+		})
+		s.buildTrees()
+
+		w := do(s, httptest.NewRequest(http.MethodGet, "/events", nil))
+		// handler返回后心跳已停止，不再写入
+		body := w.Body.String()
+		time.Sleep(3 * tt.interval)
+		if w.Body.String() != body {
+			t.Errorf("interval %v: heartbeat still running after handler returned", tt.interval)
+		}
+		if n := strings.Count(body, ": heartbeat\n\n"); n < tt.min {
+			t.Errorf("interval %v: %d heartbeats, want at least %d", tt.interval, n, tt.min)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := New()
+	var disconnected bool
+	s.GET("/stream", func(c *Context) {
+		n := 0
+		disconnected = c.Stream(func(w io.Writer) bool {
+			n++
+			io.WriteString(w, strings.Repeat("x", n))
+			return n < 3
+		})
+	})
+	s.buildTrees()
+
+	w := do(s, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if w.Body.String() != "xxxxxx" || disconnected || !w.Flushed {
+		t.Errorf("Stream = %q, disconnected %v, flushed %v", w.Body.String(), disconnected, w.Flushed)
+	}
+}
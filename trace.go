@@ -0,0 +1,145 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// trace context trace
+type trace struct {
+	Env       string          // runtime env
+	StartTime time.Time       // context start time
+	EndTime   time.Time       // context end time
+	Cost      int64           // response time (microtime)
+	ClientIP  string          // client ip
+	Route     string          // matched route pattern
+	Handler   string          // route handler name
+	Params    Any             // request params
+	Status    int             // http status code
+	Code      int             // result code
+	Message   string          // result message
+	Data      Any             // result data
+	Errors    []string        // collected errors
+	Stack     []byte          // error statck
+	Slow      bool            // slow request (watchdog)
+	Timings   []HandlerTiming // handler timings (profiling)
+	Headers   http.Header     // request headers
+	Sampled   bool            // sampling decision (head sampling, or error/slow request at the end)
+
+	TraceID    string  // W3C trace id
+	SpanID     string  // server span id
+	ParentID   string  // parent span id (from traceparent)
+	TraceState string  // W3C tracestate
+	TraceFlags string  // W3C trace flags
+	Spans      []*Span // ended child spans
+}
+
+// traceSampled 请求结束时决定是否记录trace的请求参数、请求头和结果数据：已被采样，或出错/慢请求时按配置补充记录
+func (c *Context) traceSampled() bool {
+	if c.Trace.Sampled {
+		return true
+	}
+
+	config := c.server.Config
+	if config.traceSampleErrors {
+		status := c.Trace.Status
+		if status == 0 {
+			status = c.responser.Status()
+		}
+		if status >= http.StatusInternalServerError || len(c.Trace.Errors) > 0 {
+			return true
+		}
+	}
+	return config.traceSlowThreshold > 0 && c.Trace.EndTime.Sub(c.Trace.StartTime) >= config.traceSlowThreshold
+}
+
+// sampleTraceID 按trace id低8字节决定是否采样，同一trace id在各服务中的结果一致
+func sampleTraceID(traceID string, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 || len(traceID) != 32 {
+		return false
+	}
+	n, err := strconv.ParseUint(traceID[16:], 16, 64)
+	if err != nil {
+		return false
+	}
+	return n>>1 < uint64(ratio*(1<<63))
+}
+
+// captureTraceParams 在handler执行完毕后记录请求参数
+// handler已读取过表单时直接使用表单缓存；否则仅在路由命中且Content-Type未被跳过时解析请求体
+func (c *Context) captureTraceParams() {
+	config := c.server.Config
+	if c.formCacheSlices == nil {
+		// 连接已被接管或请求体已被流式读取时不再读取请求体
+		if c.handlers == nil || c.responser.hijacked || c.bodyStreamed ||
+			skipTraceContentType(c.Request.Header.Get("Content-Type"), config.traceSkipContentTypes) {
+			return
+		}
+		c.initFormCache()
+	}
+	c.Trace.Params = capTraceParams(c.formCacheSlices, config.traceParamsMaxSize)
+}
+
+// skipTraceContentType contentType是否在跳过列表中，支持"image/*"形式的通配
+func skipTraceContentType(contentType string, skips []string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	for _, v := range skips {
+		if v == mediaType || (strings.HasSuffix(v, "/*") && strings.HasPrefix(mediaType, v[:len(v)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// capTraceParams 复制请求参数，按key排序后key和value的总长度超出maxSize时截断，maxSize <= 0 时不限制
+// 截断位置不会切开多字节的UTF-8字符
+func capTraceParams(values url.Values, maxSize int) url.Values {
+	if values == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params, size := make(url.Values, len(values)), 0
+	for _, k := range keys {
+		for _, v := range values[k] {
+			if maxSize > 0 {
+				if size+len(k) >= maxSize {
+					return params
+				}
+				if remain := maxSize - size - len(k); len(v) > remain {
+					for remain > 0 && !utf8.RuneStart(v[remain]) {
+						remain--
+					}
+					v = v[:remain] + "..."
+				}
+				size += len(k) + len(v)
+			}
+			params[k] = append(params[k], v)
+		}
+	}
+	return params
+}
@@ -0,0 +1,449 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTraceQueueSize     = 1024
+	defaultTraceWorkers       = 1
+	defaultTraceBatchSize     = 100
+	defaultTraceFlushInterval = time.Second
+)
+
+// TraceDropPolicy 队列已满时的处理策略
+type TraceDropPolicy int
+
+const (
+	// TraceDropNewest 丢弃新记录（默认）
+	TraceDropNewest TraceDropPolicy = iota
+	// TraceDropOldest 丢弃队列中最早的记录
+	TraceDropOldest
+	// TraceBlock 阻塞请求直到队列有空位
+	TraceBlock
+)
+
+// TraceRecord 请求结束时Context.Trace的深拷贝，在请求结束后由trace pipeline异步处理
+// Params和Data中的map、slice、指针及结构体导出字段会被递归复制，不与handler共享
+type TraceRecord struct {
+	Env        string
+	Method     string
+	Path       string
+	Route      string
+	Handler    string
+	StartTime  time.Time
+	EndTime    time.Time
+	Cost       int64
+	ClientIP   string
+	Params     Any
+	Status     int
+	Size       int
+	Code       int
+	Message    string
+	Data       Any
+	Errors     []string
+	Stack      []byte
+	Slow       bool
+	Timings    []HandlerTiming
+	Headers    http.Header
+	Sampled    bool
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	TraceState string
+	TraceFlags string
+	Spans      []*Span
+}
+
+// TraceSink 批量处理trace记录
+type TraceSink interface {
+	WriteTraces(records []*TraceRecord) error
+}
+
+// TraceSinkFunc 函数形式的TraceSink
+type TraceSinkFunc func(records []*TraceRecord) error
+
+// WriteTraces 实现TraceSink接口
+func (fn TraceSinkFunc) WriteTraces(records []*TraceRecord) error {
+	return fn(records)
+}
+
+// TracePipelineConfig 异步trace pipeline配置
+type TracePipelineConfig struct {
+	// 队列容量，默认1024
+	QueueSize int
+	// worker数量，默认1
+	Workers int
+	// 每批最多记录数，默认100
+	BatchSize int
+	// 未满一批时的最长等待时间，默认1s
+	FlushInterval time.Duration
+	// 队列已满时的处理策略，默认TraceDropNewest
+	DropPolicy TraceDropPolicy
+}
+
+// TracePipelineStats 异步trace pipeline统计
+type TracePipelineStats struct {
+	Queued    uint64 `json:"queued"`    // 已入队
+	Processed uint64 `json:"processed"` // sink处理成功
+	Failed    uint64 `json:"failed"`    // sink返回错误或panic
+	Dropped   uint64 `json:"dropped"`   // 因队列已满或pipeline已关闭而丢弃
+	QueueLen  int    `json:"queue_len"` // 当前队列长度
+}
+
+// tracePipeline 异步trace pipeline
+type tracePipeline struct {
+	sink   TraceSink
+	config TracePipelineConfig
+	queue  chan *TraceRecord
+	mu     sync.RWMutex // 保护closed、queue、wg与closing的关闭和替换
+	closed bool
+	wg     *sync.WaitGroup // 当前队列的worker，每次打开时重新创建
+
+	closeMu  sync.Mutex    // 保护closing的关闭
+	closing  chan struct{} // close开始时关闭，唤醒阻塞在入队的请求
+	signaled bool          // closing是否已关闭
+
+	queued    uint64
+	processed uint64
+	failed    uint64
+	dropped   uint64
+}
+
+// TraceAsync 注册异步trace sink：被采样的请求结束时复制Context.Trace为TraceRecord放入有界队列，
+// 由worker批量交给sink处理，不阻塞请求；Server.Shutdown时处理完队列中剩余的记录，再次启动Server时重新打开
+func (s *Server) TraceAsync(sink TraceSink, config ...TracePipelineConfig) {
+	cfg := TracePipelineConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultTraceQueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultTraceWorkers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultTraceBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultTraceFlushInterval
+	}
+
+	p := &tracePipeline{sink: sink, config: cfg}
+	p.start()
+
+	s.Config.trace = true
+	s.tracePipelines = append(s.tracePipelines, p)
+}
+
+// TraceStats 返回异步trace pipeline的统计，注册了多个sink时为合计值
+func (s *Server) TraceStats() (stats TracePipelineStats) {
+	for _, p := range s.tracePipelines {
+		stats.Queued += atomic.LoadUint64(&p.queued)
+		stats.Processed += atomic.LoadUint64(&p.processed)
+		stats.Failed += atomic.LoadUint64(&p.failed)
+		stats.Dropped += atomic.LoadUint64(&p.dropped)
+		p.mu.RLock()
+		stats.QueueLen += len(p.queue)
+		p.mu.RUnlock()
+	}
+	return
+}
+
+// flushTraces 关闭所有异步trace pipeline并等待队列处理完毕，返回全部pipeline的错误
+func (s *Server) flushTraces(ctx context.Context) error {
+	var errs []error
+	for _, p := range s.tracePipelines {
+		if err := p.close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reopenTraces 重新打开上次Shutdown关闭的异步trace pipeline
+func (s *Server) reopenTraces() {
+	for _, p := range s.tracePipelines {
+		p.reopen()
+	}
+}
+
+// traceRecord 复制Context.Trace为TraceRecord
+func (c *Context) traceRecord() *TraceRecord {
+	record := &TraceRecord{
+		Env:        c.Trace.Env,
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.Path,
+		Route:      c.Trace.Route,
+		Handler:    c.Trace.Handler,
+		StartTime:  c.Trace.StartTime,
+		EndTime:    c.Trace.EndTime,
+		Cost:       c.Trace.Cost,
+		ClientIP:   c.Trace.ClientIP,
+		Params:     cloneTraceValue(c.Trace.Params),
+		Status:     c.Trace.Status,
+		Size:       c.responser.Size(),
+		Code:       c.Trace.Code,
+		Message:    c.Trace.Message,
+		Data:       cloneTraceValue(c.Trace.Data),
+		Errors:     append([]string(nil), c.Trace.Errors...),
+		Stack:      append([]byte(nil), c.Trace.Stack...),
+		Slow:       c.Trace.Slow,
+		Timings:    append([]HandlerTiming(nil), c.Trace.Timings...),
+		Headers:    c.Trace.Headers.Clone(),
+		Sampled:    c.Trace.Sampled,
+		TraceID:    c.Trace.TraceID,
+		SpanID:     c.Trace.SpanID,
+		ParentID:   c.Trace.ParentID,
+		TraceState: c.Trace.TraceState,
+		TraceFlags: c.Trace.TraceFlags,
+	}
+	if record.Status == 0 {
+		record.Status = c.responser.Status()
+	}
+	if record.Size < 0 {
+		record.Size = 0
+	}
+
+	c.spansMu.Lock()
+	record.Spans = make([]*Span, 0, len(c.Trace.Spans))
+	for _, span := range c.Trace.Spans {
+		record.Spans = append(record.Spans, span.snapshot())
+	}
+	c.spansMu.Unlock()
+	return record
+}
+
+// cloneTraceValue 深拷贝trace记录中的值
+func cloneTraceValue(v Any) Any {
+	if v == nil {
+		return nil
+	}
+	return cloneValue(reflect.ValueOf(v), make(map[clonedRef]reflect.Value)).Interface()
+}
+
+// clonedRef 已复制的指针或map，用于处理循环引用
+type clonedRef struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// cloneValue 递归复制map、slice、array、指针和结构体的导出字段，其他值原样返回
+func cloneValue(v reflect.Value, seen map[clonedRef]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		ref := clonedRef{v.Pointer(), v.Type()}
+		if cp, ok := seen[ref]; ok {
+			return cp
+		}
+		if v.Kind() == reflect.Ptr {
+			cp := reflect.New(v.Type().Elem())
+			seen[ref] = cp
+			cp.Elem().Set(cloneValue(v.Elem(), seen))
+			return cp
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		seen[ref] = cp
+		iter := v.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(iter.Key(), cloneValue(iter.Value(), seen))
+		}
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(cloneValue(v.Elem(), seen))
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(cloneValue(v.Index(i), seen))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(cloneValue(v.Index(i), seen))
+		}
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := cp.Field(i); field.CanSet() {
+				field.Set(cloneValue(v.Field(i), seen))
+			}
+		}
+		return cp
+	}
+	return v
+}
+
+// enqueue 按drop policy将记录放入队列
+func (p *tracePipeline) enqueue(record *TraceRecord) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		atomic.AddUint64(&p.dropped, 1)
+		return
+	}
+
+	switch p.config.DropPolicy {
+	case TraceBlock:
+		// pipeline开始关闭时放弃等待，避免持有读锁阻塞close
+		select {
+		case p.queue <- record:
+		case <-p.closing:
+			atomic.AddUint64(&p.dropped, 1)
+			return
+		}
+	case TraceDropOldest:
+		for {
+			select {
+			case p.queue <- record:
+				atomic.AddUint64(&p.queued, 1)
+				return
+			default:
+			}
+			select {
+			case <-p.queue:
+				atomic.AddUint64(&p.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case p.queue <- record:
+		default:
+			atomic.AddUint64(&p.dropped, 1)
+			return
+		}
+	}
+	atomic.AddUint64(&p.queued, 1)
+}
+
+// start 创建队列并启动worker
+func (p *tracePipeline) start() {
+	p.queue = make(chan *TraceRecord, p.config.QueueSize)
+	p.closed = false
+	p.wg = new(sync.WaitGroup)
+	for i := 0; i < p.config.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(p.queue, p.wg)
+	}
+
+	p.closeMu.Lock()
+	p.closing = make(chan struct{})
+	p.signaled = false
+	p.closeMu.Unlock()
+}
+
+// reopen 已关闭时重新创建队列和worker，上次关闭时未处理完的worker继续处理旧队列
+func (p *tracePipeline) reopen() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.start()
+	}
+}
+
+// work 从队列读取记录，满一批或到达FlushInterval时交给sink
+func (p *tracePipeline) work(queue <-chan *TraceRecord, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*TraceRecord, 0, p.config.BatchSize)
+	for {
+		select {
+		case record, ok := <-queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= p.config.BatchSize {
+				p.flush(batch)
+				batch = make([]*TraceRecord, 0, p.config.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = make([]*TraceRecord, 0, p.config.BatchSize)
+			}
+		}
+	}
+}
+
+// flush 将一批记录交给sink
+func (p *tracePipeline) flush(batch []*TraceRecord) {
+	if len(batch) == 0 {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			atomic.AddUint64(&p.failed, uint64(len(batch)))
+			debugPrintf("[ERROR] trace sink panic: %v\n", err)
+		}
+	}()
+
+	if err := p.sink.WriteTraces(batch); err != nil {
+		atomic.AddUint64(&p.failed, uint64(len(batch)))
+		debugPrintf("[ERROR] trace sink: %v\n", err)
+		return
+	}
+	atomic.AddUint64(&p.processed, uint64(len(batch)))
+}
+
+// close 关闭队列并等待worker处理完剩余记录，ctx结束时返回ctx.Err()
+func (p *tracePipeline) close(ctx context.Context) error {
+	p.closeMu.Lock()
+	if !p.signaled {
+		p.signaled = true
+		close(p.closing)
+	}
+	p.closeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.mu.Lock()
+		if !p.closed {
+			p.closed = true
+			close(p.queue)
+		}
+		wg := p.wg
+		p.mu.Unlock()
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("nets: trace flush: %w", ctx.Err())
+	}
+}
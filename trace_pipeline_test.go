@@ -0,0 +1,202 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordSink 在内存中收集trace记录的TraceSink
+type recordSink struct {
+	mu      sync.Mutex
+	records []*TraceRecord
+	block   chan struct{} // 不为nil时WriteTraces等待其关闭
+}
+
+func (sink *recordSink) WriteTraces(records []*TraceRecord) error {
+	if sink.block != nil {
+		<-sink.block
+	}
+	sink.mu.Lock()
+	sink.records = append(sink.records, records...)
+	sink.mu.Unlock()
+	return nil
+}
+
+func (sink *recordSink) paths() []string {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	paths := make([]string, 0, len(sink.records))
+	for _, record := range sink.records {
+		paths = append(paths, record.Path)
+	}
+	return paths
+}
+
+func TestTraceAsyncFlush(t *testing.T) {
+	sink := &recordSink{}
+	s := New()
+	s.TraceAsync(sink, TracePipelineConfig{BatchSize: 2, FlushInterval: time.Hour})
+	s.GET("/users/:id", func(c *Context) {})
+	s.buildTrees()
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/3"} {
+		serve(s, http.MethodGet, path)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+
+	if got := sink.paths(); len(got) != 3 {
+		t.Fatalf("sink got %v, want 3 records", got)
+	}
+	stats := s.TraceStats()
+	if stats.Queued != 3 || stats.Processed != 3 || stats.Dropped != 0 || stats.QueueLen != 0 {
+		t.Errorf("TraceStats = %+v", stats)
+	}
+}
+
+func TestTraceAsyncDropPolicy(t *testing.T) {
+	tests := []struct {
+		policy  TraceDropPolicy
+		paths   []string
+		dropped uint64
+	}{
+		{TraceDropNewest, []string{"/1"}, 2},
+		{TraceDropOldest, []string{"/3"}, 2},
+	}
+	for _, tt := range tests {
+		sink := &recordSink{}
+		p := &tracePipeline{sink: sink, config: TracePipelineConfig{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour, DropPolicy: tt.policy}}
+		// 不启动worker，使队列保持已满
+		p.queue = make(chan *TraceRecord, 1)
+		p.wg = new(sync.WaitGroup)
+		p.closing = make(chan struct{})
+		for _, path := range []string{"/1", "/2", "/3"} {
+			p.enqueue(&TraceRecord{Path: path})
+		}
+		if got := (<-p.queue).Path; got != tt.paths[0] || p.dropped != tt.dropped {
+			t.Errorf("policy %d: queued %s, dropped %d, want %s, dropped %d", tt.policy, got, p.dropped, tt.paths[0], tt.dropped)
+		}
+	}
+}
+
+func TestTraceAsyncCloseReleasesBlockedEnqueue(t *testing.T) {
+	sink := &recordSink{block: make(chan struct{})}
+	s := New()
+	s.TraceAsync(sink, TracePipelineConfig{QueueSize: 1, BatchSize: 1, DropPolicy: TraceBlock})
+	p := s.tracePipelines[0]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.enqueue(&TraceRecord{})
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.flushTraces(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("flushTraces = %v, want deadline exceeded", err)
+	}
+	wg.Wait()
+	close(sink.block)
+}
+
+func TestTraceAsyncFlushJoinsErrors(t *testing.T) {
+	s := New()
+	blocks := []*recordSink{{block: make(chan struct{})}, {block: make(chan struct{})}}
+	for _, sink := range blocks {
+		s.TraceAsync(sink, TracePipelineConfig{BatchSize: 1})
+		s.tracePipelines[len(s.tracePipelines)-1].enqueue(&TraceRecord{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.flushTraces(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("flushTraces = %v, want canceled", err)
+	}
+	for i, p := range s.tracePipelines {
+		p.closeMu.Lock()
+		if !p.signaled {
+			t.Errorf("pipeline %d not closed", i)
+		}
+		p.closeMu.Unlock()
+	}
+	for _, sink := range blocks {
+		close(sink.block)
+	}
+}
+
+func TestTraceAsyncReopen(t *testing.T) {
+	sink := &recordSink{}
+	s := New()
+	s.TraceAsync(sink, TracePipelineConfig{BatchSize: 1})
+	s.GET("/", func(c *Context) {})
+	s.buildTrees()
+
+	serve(s, http.MethodGet, "/")
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	serve(s, http.MethodGet, "/")
+
+	s.newHTTPServer(":0")
+	serve(s, http.MethodGet, "/")
+	if err := s.flushTraces(context.Background()); err != nil {
+		t.Fatalf("flushTraces = %v", err)
+	}
+
+	stats := s.TraceStats()
+	if len(sink.paths()) != 2 || stats.Dropped != 1 {
+		t.Errorf("sink got %d records, dropped %d, want 2 and 1", len(sink.paths()), stats.Dropped)
+	}
+}
+
+type traceUser struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]int
+	Next  *traceUser
+}
+
+func TestTraceRecordDeepCopy(t *testing.T) {
+	user := &traceUser{Name: "nets", Tags: []string{"a"}, Attrs: map[string]int{"x": 1}}
+	user.Next = user
+	params := url.Values{"id": {"1"}}
+
+	s := New()
+	c := newContext(s)
+	c.init(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Trace.Params = params
+	c.Trace.Headers = http.Header{"X-Id": {"1"}}
+	c.Trace.Data = user
+	record := c.traceRecord()
+
+	user.Tags[0], user.Attrs["x"], params["id"][0] = "b", 2, "2"
+	c.Trace.Headers["X-Id"][0] = "2"
+
+	data := record.Data.(*traceUser)
+	if data == user || data.Next != data || data.Tags[0] != "a" || data.Attrs["x"] != 1 {
+		t.Errorf("record.Data shares state with handler data: %+v", data)
+	}
+	if got := record.Params.(url.Values).Get("id"); got != "1" {
+		t.Errorf("record.Params id = %s, want 1", got)
+	}
+	if got := record.Headers.Get("X-Id"); got != "1" {
+		t.Errorf("record.Headers X-Id = %s, want 1", got)
+	}
+}
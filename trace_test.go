@@ -0,0 +1,124 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCapTraceParams(t *testing.T) {
+	values := url.Values{"b": {"22", "3"}, "a": {"1"}, "c": {"中文字符"}}
+	tests := []struct {
+		maxSize int
+		want    url.Values
+	}{
+		{0, values},
+		{-1, values},
+		{2, url.Values{"a": {"1"}}},
+		{4, url.Values{"a": {"1"}, "b": {"2..."}}},
+		{5, url.Values{"a": {"1"}, "b": {"22"}}},
+		{8, url.Values{"a": {"1"}, "b": {"22", "3"}}},
+		{10, url.Values{"a": {"1"}, "b": {"22", "3"}, "c": {"..."}}},
+		{11, url.Values{"a": {"1"}, "b": {"22", "3"}, "c": {"中..."}}},
+		{13, url.Values{"a": {"1"}, "b": {"22", "3"}, "c": {"中..."}}},
+		{15, url.Values{"a": {"1"}, "b": {"22", "3"}, "c": {"中文..."}}},
+	}
+	for _, tt := range tests {
+		// 多次执行结果相同，不受map遍历顺序影响
+		for i := 0; i < 10; i++ {
+			got := capTraceParams(values, tt.maxSize)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("capTraceParams(%d) = %v, want %v", tt.maxSize, got, tt.want)
+			}
+			for _, v := range got["c"] {
+				if !utf8.ValidString(v) {
+					t.Fatalf("capTraceParams(%d) cut a rune: %q", tt.maxSize, v)
+				}
+			}
+		}
+	}
+}
+
+// multipartBody 返回包含字段name=nets的multipart请求体及其Content-Type
+func multipartBody() (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("name", "nets")
+	writer.Close()
+	return body, writer.FormDataContentType()
+}
+
+func TestCaptureTraceParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        func() (*bytes.Buffer, string)
+		want        url.Values
+	}{
+		{"query only", "/lazy?id=1", "", nil, url.Values{}},
+		{"urlencoded", "/lazy?id=1", "application/x-www-form-urlencoded", nil, url.Values{"name": {"nets"}}},
+		{"octet-stream skipped", "/lazy?id=1", "application/octet-stream", nil, nil},
+		{"multipart skipped", "/lazy?id=1", "", multipartBody, nil},
+		{"multipart read by handler", "/form?id=1", "", multipartBody, url.Values{"name": {"nets"}}},
+		{"streamed", "/stream?id=1", "", multipartBody, nil},
+		{"not found", "/none?id=1", "application/x-www-form-urlencoded", nil, nil},
+	}
+	for _, tt := range tests {
+		s := New()
+		var params interface{}
+		s.Trace(func(c *Context) { params = c.Trace.Params })
+		s.POST("/lazy", func(c *Context) {})
+		s.POST("/form", func(c *Context) { c.FormArray("name") })
+		s.POST("/stream", func(c *Context) {
+			c.StreamMultipart(func(part *multipart.Part) error { return nil })
+		})
+		s.buildTrees()
+
+		body, contentType := bytes.NewBufferString("name=nets"), tt.contentType
+		if tt.body != nil {
+			body, contentType = tt.body()
+		}
+		req := httptest.NewRequest(http.MethodPost, tt.path, body)
+		req.Header.Set("Content-Type", contentType)
+		do(s, req)
+
+		got, _ := params.(url.Values)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Trace.Params = %v, want %v", tt.name, params, tt.want)
+		}
+	}
+}
+
+func TestCaptureTraceParamsHijacked(t *testing.T) {
+	s := New()
+	params := make(chan interface{}, 1)
+	s.Trace(func(c *Context) { params <- c.Trace.Params })
+	s.POST("/hijack", func(c *Context) {
+		conn, _, err := c.responser.Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	})
+	s.buildTrees()
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/hijack", "application/x-www-form-urlencoded", strings.NewReader("name=nets"))
+	if err == nil {
+		resp.Body.Close()
+	}
+	if got := <-params; got != nil {
+		t.Errorf("Trace.Params after hijack = %v, want nil", got)
+	}
+}
@@ -10,12 +10,15 @@ import (
 
 // node 路由前缀树结点
 type node struct {
-	pattern     string       // 结点片段路由规则
-	fullPattern string       // 结点完整路由规则
-	handlers    HandlerChain // 私有处理函数
-	middlewares HandlerChain // 共享处理函数
-	paramKeys   []string     // 路由参数名称数组
-	children    []*node      // 孩子结点
+	pattern         string       // 结点片段路由规则
+	fullPattern     string       // 结点完整路由规则
+	path            string       // 注册的完整路由（含路由参数名），仅有私有处理函数的结点有值
+	handlers        HandlerChain // 私有处理函数
+	middlewares     HandlerChain // 共享处理函数
+	handlerNames    []string     // 私有处理函数名称
+	middlewareNames []string     // 共享处理函数名称
+	paramKeys       []string     // 路由参数名称数组
+	children        []*node      // 孩子结点
 }
 
 // methodTree HTTP method tree
@@ -68,9 +71,9 @@ func middlewareMetas(mMetas methodMetas) (middlewares []meta) {
 		if v.method == methodMiddleware {
 			middlewares = v.metas
 			for key, value := range middlewares {
-				maps := map[int][]HandlerChain{}
+				maps := map[int][]priorityHandlers{}
 				for _, v := range value.priorityHandlerses {
-					maps[v.priority] = append(maps[v.priority], v.handlers)
+					maps[v.priority] = append(maps[v.priority], v)
 				}
 
 				priorities := []int{}
@@ -79,14 +82,16 @@ func middlewareMetas(mMetas methodMetas) (middlewares []meta) {
 				}
 				sort.Ints(priorities)
 
-				handlers := HandlerChain{}
+				handlers, names := HandlerChain{}, []string{}
 				for _, priority := range priorities {
 					for _, v := range maps[priority] {
-						handlers = append(handlers, v...)
+						handlers = append(handlers, v.handlers...)
+						names = append(names, v.names...)
 					}
 				}
 
 				middlewares[key].handlers = handlers
+				middlewares[key].handlerNames = names
 				middlewares[key].priorityHandlerses = nil
 			}
 			return
@@ -100,31 +105,38 @@ func createTree(root *node, startx int, fullPattern string, routeMetas []meta, m
 		// 按照优先级生成树，优先级越高越靠左
 		for _, key := range sortGroupsKeys(mGroups) {
 			mGroup := mGroups[key]
-			middlewares := HandlerChain{}
+			middlewares, middlewareNames := HandlerChain{}, []string{}
 			fpattern := fullPattern + mGroup.pattern
 			for _, w := range middlewareMetas {
 				if fpattern == w.pattern {
 					middlewares = w.handlers
+					middlewareNames = w.handlerNames
 					break
 				}
 			}
 
-			handlers, paramKeys := HandlerChain{}, []string{}
+			handlers, handlerNames, paramKeys := HandlerChain{}, []string{}, []string{}
 			for k, v := range mGroup.metas {
 				if v.pattern == fpattern {
 					handlers = mGroup.metas[k].handlers
+					handlerNames = mGroup.metas[k].handlerNames
 					paramKeys = mGroup.metas[k].paramKeys
 					break
 				}
 			}
 
 			child := &node{
-				pattern:     mGroup.pattern,
-				fullPattern: fpattern,
-				handlers:    handlers,
-				middlewares: middlewares,
-				paramKeys:   paramKeys,
-				children:    make([]*node, 0),
+				pattern:         mGroup.pattern,
+				fullPattern:     fpattern,
+				handlers:        handlers,
+				middlewares:     middlewares,
+				handlerNames:    handlerNames,
+				middlewareNames: middlewareNames,
+				paramKeys:       paramKeys,
+				children:        make([]*node, 0),
+			}
+			if len(handlers) > 0 {
+				child.path = restorePattern(fpattern, paramKeys)
 			}
 
 			debugPrintf("%+v\n", child)
@@ -230,11 +242,12 @@ func isIndexCharCommon(index int, metas []meta, middlewareMetas []meta) (bool, b
 		if index > 0 && char == routeParamIdentifierByte && v.pattern[index-1] == slashByte {
 			return false, false
 		}
+	}
 
-		for _, vv := range middlewareMetas {
-			if v.pattern[:index+1] == vv.pattern {
-				return false, true
-			}
+	// 所有metas在index处的字符相同后，再判断是否为中间件的边界
+	for _, vv := range middlewareMetas {
+		if metas[0].pattern[:index+1] == vv.pattern {
+			return false, true
 		}
 	}
 	return true, true
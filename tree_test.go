@@ -0,0 +1,90 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouteGroupPrefixSibling(t *testing.T) {
+	s := New()
+	s.Group("/admin/", mark("admin"))
+	s.GET("/admin/users", mark("users"))
+	s.GET("/adminx", mark("adminx"))
+	s.GET("/admin", mark("admin-index"))
+	s.buildTrees()
+
+	tests := []struct {
+		path     string
+		status   int
+		handlers string
+	}{
+		{"/admin/users", http.StatusOK, "admin,users"},
+		{"/adminx", http.StatusOK, "adminx"},
+		{"/admin", http.StatusOK, "admin-index"},
+		{"/adminy", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		status, handlers := serve(s, http.MethodGet, tt.path)
+		if status != tt.status || handlers != tt.handlers {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, status, handlers, tt.status, tt.handlers)
+		}
+	}
+}
+
+func TestRouteOverlappingMiddlewares(t *testing.T) {
+	s := New()
+	s.Use(mark("global"))
+	s.Group("/api/", mark("api"))
+	s.Group("/api/v1/", mark("v1"))
+	s.GET("/api/v1/users", mark("v1-users"))
+	s.GET("/api/v1x/users", mark("v1x-users"))
+	s.GET("/api/v2/users", mark("v2-users"))
+	s.GET("/apix", mark("apix"))
+	s.buildTrees()
+
+	tests := []struct {
+		path     string
+		handlers string
+	}{
+		{"/api/v1/users", "global,api,v1,v1-users"},
+		{"/api/v1x/users", "global,api,v1x-users"},
+		{"/api/v2/users", "global,api,v2-users"},
+		{"/apix", "global,apix"},
+	}
+	for _, tt := range tests {
+		status, handlers := serve(s, http.MethodGet, tt.path)
+		if status != http.StatusOK || handlers != tt.handlers {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, status, handlers, http.StatusOK, tt.handlers)
+		}
+	}
+}
+
+func TestRouteGroupParams(t *testing.T) {
+	s := New()
+	s.Group("/users/", mark("users"))
+	s.GET("/users/new", mark("new"))
+	s.GET("/users/:id", func(c *Context) {
+		mark("user:" + c.ParamMust("id"))(c)
+	})
+	s.GET("/usersearch", mark("search"))
+	s.buildTrees()
+
+	tests := []struct {
+		path     string
+		handlers string
+	}{
+		{"/users/new", "users,new"},
+		{"/users/42", "users,user:42"},
+		{"/usersearch", "search"},
+	}
+	for _, tt := range tests {
+		status, handlers := serve(s, http.MethodGet, tt.path)
+		if status != http.StatusOK || handlers != tt.handlers {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, status, handlers, http.StatusOK, tt.handlers)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// Typed 将强类型函数适配为HandlerFunc：
+// 使用Context.Bind绑定并校验Req，调用fn，并将Resp作为Result.Data以json输出；
+// Resp为Result类型时原样输出。绑定失败返回ErrorTypeBind类型的错误，fn返回的错误原样返回，
+// 两者均由Server的ErrorMapper映射为响应。Req须为结构体或结构体指针，否则注册时panic
+func Typed[Req any, Resp any](fn func(*Context, Req) (Resp, error)) HandlerFunc {
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	structType := reqType
+	if reqType.Kind() == reflect.Ptr {
+		structType = reqType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		panic("nets: Typed request type must be a struct or a pointer to struct, got " + reqType.String())
+	}
+
+	return wrapHandlerFuncE(nameOfFunction(fn), func(c *Context) error {
+		var req Req
+		target := interface{}(&req)
+		if structType != reqType {
+			// Req为指针时分配新的结构体并绑定到该结构体
+			ptr := reflect.New(structType)
+			req, target = ptr.Interface().(Req), ptr.Interface()
+		}
+		if err := c.Bind(target); err != nil {
+			return &Error{Err: err, Type: ErrorTypeBind}
+		}
+
+		resp, err := fn(c, req)
+		if err != nil {
+			return err
+		}
+
+		// 输出错误已由render记录到Context.Errors
+		if result, ok := any(resp).(Result); ok {
+			c.JSON(http.StatusOK, result)
+		} else {
+			c.JSON(http.StatusOK, Result{Data: resp})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindBase struct {
+	Token string `header:"X-Token" binding:"required"`
+}
+
+type bindUser struct {
+	bindBase
+	ID      int64         `path:"id"`
+	Name    string        `query:"name" form:"name" binding:"omitempty, required"`
+	Tags    []string      `query:"tags"`
+	Timeout time.Duration `query:"timeout"`
+	Since   *time.Time    `query:"since"`
+	Age     uint8         `form:"age"`
+	secret  string        `query:"secret"`
+}
+
+func (u *bindUser) Validate() error {
+	if u.Name == "root" {
+		return errors.New("name is reserved")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		token       string
+		err         string
+		want        string
+	}{
+		{"query", http.MethodGet, "/users/7?name=nets&tags=a&tags=b&timeout=2s&since=2020-01-02T00:00:00Z&secret=x", "", "", "t",
+			"", "t 7 nets [a b] 2s 2020 0 \"\""},
+		{"json", http.MethodPost, "/users/7", MIMEJSON, `{"Name":"json","Age":3}`, "t", "", "t 7 json [] 0s 0 3 \"\""},
+		{"form", http.MethodPost, "/users/7", "application/x-www-form-urlencoded", "name=form&age=30", "t", "", "t 7 form [] 0s 0 30 \"\""},
+		{"query over json", http.MethodPost, "/users/7?name=query", MIMEJSON, `{"Name":"json"}`, "t", "", "t 7 query [] 0s 0 0 \"\""},
+		{"empty json body", http.MethodPost, "/users/7?name=nets", MIMEJSON, "", "t", "", "t 7 nets [] 0s 0 0 \"\""},
+		{"missing header", http.MethodGet, "/users/7?name=nets", "", "", "", "field Token is required", ""},
+		{"missing name", http.MethodGet, "/users/7", "", "", "t", "field Name is required", ""},
+		{"bad path", http.MethodGet, "/users/x?name=nets", "", "", "t", `bind path "id"`, ""},
+		{"overflow", http.MethodPost, "/users/7", "application/x-www-form-urlencoded", "name=nets&age=300", "t", `bind form "age"`, ""},
+		{"bad duration", http.MethodGet, "/users/7?name=nets&timeout=2", "", "", "t", `bind query "timeout"`, ""},
+		{"bad json", http.MethodPost, "/users/7", MIMEJSON, `{"Name":`, "t", "unexpected end of JSON input", ""},
+		{"validator", http.MethodGet, "/users/7?name=root", "", "", "t", "name is reserved", ""},
+	}
+	for _, tt := range tests {
+		s := New()
+		var user bindUser
+		var bindErr error
+		bind := func(c *Context) { bindErr = c.Bind(&user) }
+		s.GET("/users/:id", bind)
+		s.POST("/users/:id", bind)
+		s.buildTrees()
+
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.token != "" {
+			req.Header.Set("X-Token", tt.token)
+		}
+		do(s, req)
+
+		if tt.err != "" {
+			if bindErr == nil || !strings.Contains(bindErr.Error(), tt.err) {
+				t.Errorf("%s: Bind = %v, want error containing %q", tt.name, bindErr, tt.err)
+			}
+			continue
+		}
+		year := 0
+		if user.Since != nil {
+			year = user.Since.Year()
+		}
+		got := fmt.Sprintf("%s %d %s %v %s %d %d %q", user.Token, user.ID, user.Name, user.Tags, user.Timeout, year, user.Age, user.secret)
+		if bindErr != nil || got != tt.want {
+			t.Errorf("%s: Bind = %v, user %s, want %s", tt.name, bindErr, got, tt.want)
+		}
+	}
+}
+
+func TestBindInvalidTarget(t *testing.T) {
+	c, _ := testContext(New(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var user *bindUser
+	name := ""
+	for _, target := range []interface{}{bindUser{}, user, &name, nil} {
+		if err := c.Bind(target); err != ErrInvalidBindTarget {
+			t.Errorf("Bind(%T) = %v, want ErrInvalidBindTarget", target, err)
+		}
+	}
+}
+
+type typedReq struct {
+	ID   int    `path:"id"`
+	Name string `query:"name" binding:"required"`
+}
+
+type typedResp struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTyped(t *testing.T) {
+	getUser := func(c *Context, req typedReq) (typedResp, error) {
+		if req.ID == 0 {
+			return typedResp{}, errors.New("db down")
+		}
+		return typedResp{ID: req.ID, Name: req.Name}, nil
+	}
+	getResult := func(c *Context, req *typedReq) (Result, error) {
+		return Result{Code: req.ID, Message: req.Name}, nil
+	}
+	streamOnly := func(c *Context, req typedReq) (io.Reader, error) {
+		return nil, &Error{Err: errors.New("gone"), Type: ErrorTypePublic, Status: http.StatusGone}
+	}
+
+	s := New()
+	s.GET("/users/:id", Typed(getUser))
+	s.GET("/results/:id", Typed(getResult))
+	s.GET("/gone/:id", Typed(streamOnly))
+	s.buildTrees()
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/users/7?name=nets", http.StatusOK, `{"code":0,"message":"","data":{"id":7,"name":"nets"}}`},
+		{"/users/7", http.StatusBadRequest, `{"code":400,"message":"nets: field Name is required","data":{}}`},
+		{"/users/0?name=nets", http.StatusInternalServerError, `{"code":500,"message":"Internal Server Error","data":{}}`},
+		{"/results/7?name=nets", http.StatusOK, `{"code":7,"message":"nets","data":{}}`},
+		{"/gone/7?name=nets", http.StatusGone, `{"code":410,"message":"gone","data":{}}`},
+	}
+	for _, tt := range tests {
+		w := do(s, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.status || w.Body.String() != tt.body {
+			t.Errorf("GET %s = %d %s, want %d %s", tt.target, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+}
+
+func TestTypedInvalidRequestType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Typed with non-struct request type did not panic")
+		}
+	}()
+	Typed(func(c *Context, id int) (int, error) { return id, nil })
+}
@@ -4,12 +4,106 @@
 
 package nets
 
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode"
+)
+
 // Any interface{}
 type Any interface{}
 
 // AnyMap map[string]Any
 type AnyMap map[string]Any
 
+// MarshalXML 实现xml.Marshaler接口，按key排序输出子元素
+// key不是合法的xml元素名时输出为<entry key="...">，值中的map同样按子元素输出
+func (m AnyMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		element := xml.StartElement{Name: xml.Name{Local: k}}
+		if !isXMLName(k) {
+			element = xml.StartElement{
+				Name: xml.Name{Local: "entry"},
+				Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: k}},
+			}
+		}
+		if err := e.EncodeElement(xmlValue(m[k]), element); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// marshalXML 序列化为xml，Result.Data中任意类型的map转换为AnyMap输出
+func marshalXML(v interface{}) ([]byte, error) {
+	if result, ok := v.(Result); ok {
+		result.Data = xmlValue(result.Data)
+		v = result
+	}
+	return xml.Marshal(v)
+}
+
+// xmlValue 将map（及指向map的指针）转换为AnyMap，切片和数组中的元素逐个转换，其他值原样返回
+func xmlValue(v Any) Any {
+	if _, ok := v.(xml.Marshaler); ok || v == nil {
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if !rv.IsNil() && rv.Elem().Kind() == reflect.Map {
+			return xmlValue(rv.Elem().Interface())
+		}
+	case reflect.Map:
+		m := make(AnyMap, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		items := make([]Any, rv.Len())
+		for i := range items {
+			items[i] = xmlValue(rv.Index(i).Interface())
+		}
+		return items
+	}
+	return v
+}
+
+// isXMLName name是否可直接作为xml元素名：字母或_开头，由字母、数字、_、-、.组成，且不以xml开头
+func isXMLName(name string) bool {
+	if name == "" || len(name) >= 3 && (name[0]|0x20) == 'x' && (name[1]|0x20) == 'm' && (name[2]|0x20) == 'l' {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // Entry kv string
 type Entry struct {
 	Key   string
@@ -0,0 +1,179 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// sniffLen http.DetectContentType最多读取的字节数
+	sniffLen = 512
+)
+
+// ErrFileTypeNotAllowed 上传文件类型不被允许
+var ErrFileTypeNotAllowed = errors.New("nets: file type not allowed")
+
+// MaxBodySize 返回限制请求体大小的中间件，超出limit时响应413
+// Content-Length已超出时立即终止，否则在读取请求体超出时由读取方得到*http.MaxBytesError
+func MaxBodySize(limit int64) HandlerFunc {
+	return func(c *Context) {
+		if c.Request.ContentLength > limit {
+			c.Error(&http.MaxBytesError{Limit: limit}).SetType(ErrorTypePublic).SetStatus(http.StatusRequestEntityTooLarge)
+			c.AbortStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(&c.responser, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
+
+// MultipartForm 返回解析后的multipart表单，文件超出multipartMemoryMax的部分写入临时文件，
+// 临时文件在Context重置时删除
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	c.guard()
+	if c.Request.MultipartForm == nil {
+		if err := c.Request.ParseMultipartForm(c.server.Config.multipartMemoryMax); err != nil {
+			return nil, err
+		}
+	}
+	return c.Request.MultipartForm, nil
+}
+
+// FormFile 返回表单上传文件name的第一个文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile 将上传文件保存到dst，dst所在目录不存在时自动创建
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	c.guard()
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// CheckFileType 嗅探上传文件的内容类型，不在allowed中时返回ErrFileTypeNotAllowed
+// allowed支持"image/*"形式的通配，为空时不做限制
+func (c *Context) CheckFileType(file *multipart.FileHeader, allowed ...string) (string, error) {
+	c.guard()
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	contentType, _, err := SniffContentType(src)
+	if err != nil {
+		return "", err
+	}
+	if !AllowedContentType(contentType, allowed...) {
+		return contentType, ErrFileTypeNotAllowed
+	}
+	return contentType, nil
+}
+
+// StreamMultipart 流式读取multipart请求体，每个part依次交给handle处理，不写入内存缓存或临时文件
+// 不能与Form、FormFile等会解析整个表单的方法混用
+func (c *Context) StreamMultipart(handle func(part *multipart.Part) error) error {
+	c.guard()
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return err
+	}
+	c.bodyStreamed = true
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = handle(part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SniffContentType 读取r的前512字节嗅探内容类型，返回的io.Reader可从头读取完整数据
+func SniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+	return http.DetectContentType(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// AllowedContentType contentType是否在allowed中，allowed为空时返回true
+func AllowedContentType(contentType string, allowed ...string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	for _, v := range allowed {
+		v = strings.ToLower(v)
+		if v == mediaType || v == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(v, "/*") && strings.HasPrefix(mediaType, v[:len(v)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeMultipartFiles 删除解析multipart表单时产生的临时文件
+func (c *Context) removeMultipartFiles() {
+	if c.Request != nil && c.Request.MultipartForm != nil {
+		if err := c.Request.MultipartForm.RemoveAll(); err != nil {
+			debugPrintf("[ERROR] cannot remove multipart temp files: %v\n", err)
+		}
+	}
+}
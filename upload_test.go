@@ -0,0 +1,228 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pngData PNG文件头
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+// uploadRequest 返回上传表单请求，files为字段名到文件内容的映射
+func uploadRequest(target string, fields map[string]string, files map[string][]byte) *http.Request {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	for name, data := range files {
+		part, _ := writer.CreateFormFile(name, name+".bin")
+		part.Write(data)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		status  int
+		readErr bool
+	}{
+		{"under limit", "12345", false, http.StatusOK, false},
+		{"content-length over limit", "123456789", false, http.StatusRequestEntityTooLarge, false},
+		{"chunked under limit", "12345", true, http.StatusOK, false},
+		{"chunked over limit", "123456789", true, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		s := New()
+		var readErr error
+		s.Use(MaxBodySize(8))
+		s.POST("/", mark("handler"), func(c *Context) {
+			_, readErr = ioutil.ReadAll(c.Request.Body)
+		})
+		s.buildTrees()
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		w := do(s, req)
+
+		var maxBytesErr *http.MaxBytesError
+		if w.Code != tt.status || errors.As(readErr, &maxBytesErr) != tt.readErr {
+			t.Errorf("%s: status %d, read error %v, want %d, read error %v", tt.name, w.Code, readErr, tt.status, tt.readErr)
+		}
+		if ran := w.Header().Get("X-Handlers") != ""; ran != (tt.status == http.StatusOK) {
+			t.Errorf("%s: handler ran = %v", tt.name, ran)
+		}
+	}
+}
+
+func TestUploadedFile(t *testing.T) {
+	dir := t.TempDir()
+	s := New()
+	s.Config.SetMultipartMemoryMax(1)
+	var tmpFile string
+	var errs []error
+	var contentType string
+	s.POST("/upload", func(c *Context) {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if src, err := file.Open(); err == nil {
+			if f, ok := src.(*os.File); ok {
+				tmpFile = f.Name()
+			}
+			src.Close()
+		}
+		contentType, err = c.CheckFileType(file, "image/*")
+		errs = append(errs, err)
+		_, err = c.CheckFileType(file, "application/pdf")
+		errs = append(errs, err)
+		errs = append(errs, c.SaveUploadedFile(file, filepath.Join(dir, "a", "b", "avatar.png")))
+		_, err = c.FormFile("none")
+		errs = append(errs, err)
+		if form, err := c.MultipartForm(); err != nil || form.Value["name"][0] != "nets" {
+			errs = append(errs, errors.New("form value name missing"))
+		}
+	})
+	s.buildTrees()
+
+	do(s, uploadRequest("/upload", map[string]string{"name": "nets"}, map[string][]byte{"avatar": pngData}))
+
+	want := []error{nil, ErrFileTypeNotAllowed, nil, http.ErrMissingFile}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("error %d = %v, want %v", i, errs[i], want[i])
+		}
+	}
+	if contentType != "image/png" {
+		t.Errorf("CheckFileType = %s, want image/png", contentType)
+	}
+	if saved, err := ioutil.ReadFile(filepath.Join(dir, "a", "b", "avatar.png")); err != nil || !bytes.Equal(saved, pngData) {
+		t.Errorf("saved file = %d bytes, %v", len(saved), err)
+	}
+	// 超出multipartMemoryMax的文件写入临时文件，请求结束后删除
+	if tmpFile == "" {
+		t.Fatal("upload was not written to a temp file")
+	}
+	if _, err := os.Stat(tmpFile); !os.IsNotExist(err) {
+		t.Errorf("temp file %s not removed: %v", tmpFile, err)
+	}
+}
+
+func TestStreamMultipart(t *testing.T) {
+	tests := []struct {
+		name   string
+		stop   string // 处理到该part时返回错误
+		parts  string
+		failed bool
+	}{
+		{"all parts", "", "avatar:73,name:4", false},
+		{"handler error", "avatar", "avatar:73", true},
+	}
+	for _, tt := range tests {
+		s := New()
+		var parts []string
+		var streamErr error
+		s.POST("/upload", func(c *Context) {
+			streamErr = c.StreamMultipart(func(part *multipart.Part) error {
+				data, _ := ioutil.ReadAll(part)
+				parts = append(parts, part.FormName()+":"+strconv.Itoa(len(data)))
+				if part.FormName() == tt.stop {
+					return errors.New("stop")
+				}
+				return nil
+			})
+		})
+		s.buildTrees()
+
+		body := new(bytes.Buffer)
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("avatar", "avatar.png")
+		part.Write(append(pngData, '!'))
+		writer.WriteField("name", "nets")
+		writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		do(s, req)
+
+		if strings.Join(parts, ",") != tt.parts || (streamErr != nil) != tt.failed {
+			t.Errorf("%s: parts %v, err %v, want %s", tt.name, parts, streamErr, tt.parts)
+		}
+	}
+
+	c, _ := testContext(New(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=1")))
+	if err := c.StreamMultipart(func(part *multipart.Part) error { return nil }); err == nil {
+		t.Error("StreamMultipart on non-multipart request succeeded")
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		data        []byte
+		contentType string
+	}{
+		{pngData, "image/png"},
+		{[]byte("%PDF-1.4"), "application/pdf"},
+		{[]byte("hello"), "text/plain; charset=utf-8"},
+		{[]byte{}, "text/plain; charset=utf-8"},
+		{bytes.Repeat([]byte{0x01}, 2048), "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		contentType, r, err := SniffContentType(bytes.NewReader(tt.data))
+		if err != nil || contentType != tt.contentType {
+			t.Errorf("SniffContentType = %s, %v, want %s", contentType, err, tt.contentType)
+			continue
+		}
+		// 返回的reader可读取完整数据
+		if data, _ := ioutil.ReadAll(r); !bytes.Equal(data, tt.data) {
+			t.Errorf("SniffContentType reader returned %d bytes, want %d", len(data), len(tt.data))
+		}
+	}
+}
+
+func TestAllowedContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		allowed     []string
+		want        bool
+	}{
+		{"image/png", nil, true},
+		{"image/png", []string{"image/png"}, true},
+		{"image/png", []string{"IMAGE/*"}, true},
+		{"text/plain; charset=utf-8", []string{"text/plain"}, true},
+		{"image/png", []string{"*/*"}, true},
+		{"image/png", []string{"image/jpeg", "application/*"}, false},
+		{"imagex/png", []string{"image/*"}, false},
+	}
+	for _, tt := range tests {
+		if got := AllowedContentType(tt.contentType, tt.allowed...); got != tt.want {
+			t.Errorf("AllowedContentType(%s, %v) = %v, want %v", tt.contentType, tt.allowed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWatchdogThreshold = time.Second
+	defaultWatchdogTopN      = 10
+	defaultWatchdogWindow    = 5 * time.Minute
+	// 默认栈快照最小间隔
+	defaultWatchdogSnapshotInterval = 10 * time.Second
+	// 获取全部goroutine栈时的初始缓冲区大小
+	watchdogStackBufSize = 64 << 10
+	// 全部goroutine栈的最大长度
+	watchdogStackMaxSize = 8 << 20
+)
+
+// WatchdogConfig 慢请求监控配置
+type WatchdogConfig struct {
+	// 慢请求阈值，默认1s
+	Threshold time.Duration
+	// 请求运行超过阈值时输出goroutine栈快照，为nil时使用os.Stderr
+	Output io.Writer
+	// 慢路由报告的路由数量，默认10
+	TopN int
+	// 慢路由统计的滚动窗口，报告覆盖最近一到两个窗口，默认5分钟
+	Window time.Duration
+	// 栈快照的最小间隔，默认10s；获取栈快照需要暂停全部goroutine，间隔内的其他慢请求只输出一行警告
+	SnapshotInterval time.Duration
+	// 是否为慢请求记录各handler耗时，默认关闭；开启后每个请求的每个handler都会计时，
+	// 请求未超过阈值时丢弃结果；Configure.SetProfiling开启时总是记录
+	Breakdown bool
+}
+
+// Watchdog 慢请求监控：请求运行超过阈值时输出该请求goroutine的栈快照，
+// 请求结束后将慢请求（开启Breakdown或profiling时包括各handler耗时）记录到Context.Trace，并按路由统计最慢的请求
+//
+//	watchdog := nets.NewWatchdog(nets.WatchdogConfig{Threshold: 500 * time.Millisecond})
+//	s.PriorityUse(1, watchdog.Middleware())
+//	s.GET("/admin/slow-routes", watchdog.Report())
+type Watchdog struct {
+	config       WatchdogConfig
+	output       sync.Mutex
+	lastSnapshot int64 // 上次获取栈快照的时间（UnixNano）
+
+	mu          sync.Mutex
+	windowStart time.Time
+	current     map[routeKey]*RouteLatency
+	previous    map[routeKey]*RouteLatency
+}
+
+// routeKey 路由统计的key
+type routeKey struct {
+	method string
+	route  string
+}
+
+// RouteLatency 路由耗时统计
+type RouteLatency struct {
+	Method  string        `json:"method"`
+	Route   string        `json:"route"`
+	Count   int64         `json:"count"`    // 请求数
+	Slow    int64         `json:"slow"`     // 慢请求数
+	Total   time.Duration `json:"total"`    // 总耗时
+	Max     time.Duration `json:"max"`      // 最大耗时
+	Average time.Duration `json:"average"`  // 平均耗时
+	LastAt  time.Time     `json:"last_at"`  // 最近一次慢请求时间
+	MaxPath string        `json:"max_path"` // 最大耗时请求的路径
+}
+
+// NewWatchdog 返回新的Watchdog
+func NewWatchdog(config ...WatchdogConfig) *Watchdog {
+	cfg := WatchdogConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultWatchdogThreshold
+	}
+	if cfg.Output == nil {
+		cfg.Output = os.Stderr
+	}
+	if cfg.TopN <= 0 {
+		cfg.TopN = defaultWatchdogTopN
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWatchdogWindow
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = defaultWatchdogSnapshotInterval
+	}
+
+	return &Watchdog{
+		config:      cfg,
+		windowStart: time.Now(),
+		current:     make(map[routeKey]*RouteLatency),
+	}
+}
+
+// Middleware 返回慢请求监控中间件，应以较高优先级注册，以便统计后续全部handler的耗时
+func (w *Watchdog) Middleware() HandlerFunc {
+	return func(c *Context) {
+		method, path, route := c.Request.Method, c.Request.URL.Path, c.FullPath()
+		goid := currentGoroutineID()
+		start := time.Now()
+		timer := time.AfterFunc(w.config.Threshold, func() {
+			w.snapshot(method, path, route, goid, start)
+		})
+
+		// 仅丢弃由watchdog开启的耗时记录
+		breakdown := w.config.Breakdown && !c.profiling
+		if breakdown {
+			c.Profile()
+		}
+		defer func() {
+			timer.Stop()
+			cost := time.Since(start)
+			slow := cost >= w.config.Threshold
+			if slow {
+				c.Trace.Slow = true
+			} else if breakdown {
+				c.Trace.Timings = nil
+			}
+			w.observe(method, route, path, cost, slow)
+		}()
+		c.Next()
+	}
+}
+
+// Report 返回输出最慢路由报告的handler
+func (w *Watchdog) Report() HandlerFunc {
+	return func(c *Context) {
+		c.JSON(http.StatusOK, Result{Data: w.Slowest()})
+	}
+}
+
+// Slowest 返回最近窗口内按最大耗时降序排列的前TopN个路由
+func (w *Watchdog) Slowest() []RouteLatency {
+	w.mu.Lock()
+	w.rotate(time.Now())
+	merged := make(map[routeKey]RouteLatency, len(w.current)+len(w.previous))
+	for _, stats := range []map[routeKey]*RouteLatency{w.previous, w.current} {
+		for k, v := range stats {
+			m, ok := merged[k]
+			if !ok {
+				merged[k] = *v
+				continue
+			}
+			m.Count += v.Count
+			m.Slow += v.Slow
+			m.Total += v.Total
+			if v.Max > m.Max {
+				m.Max, m.MaxPath = v.Max, v.MaxPath
+			}
+			if v.LastAt.After(m.LastAt) {
+				m.LastAt = v.LastAt
+			}
+			merged[k] = m
+		}
+	}
+	w.mu.Unlock()
+
+	list := make([]RouteLatency, 0, len(merged))
+	for _, v := range merged {
+		if v.Count > 0 {
+			v.Average = v.Total / time.Duration(v.Count)
+		}
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Max > list[j].Max })
+	if len(list) > w.config.TopN {
+		list = list[:w.config.TopN]
+	}
+	return list
+}
+
+// observe 记录一次请求耗时
+func (w *Watchdog) observe(method, route, path string, cost time.Duration, slow bool) {
+	now := time.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rotate(now)
+	key := routeKey{method: method, route: route}
+	stats, ok := w.current[key]
+	if !ok {
+		stats = &RouteLatency{Method: method, Route: route}
+		w.current[key] = stats
+	}
+	stats.Count++
+	stats.Total += cost
+	if cost > stats.Max {
+		stats.Max, stats.MaxPath = cost, path
+	}
+	if slow {
+		stats.Slow++
+		stats.LastAt = now
+	}
+}
+
+// rotate 当前窗口结束时滚动
+func (w *Watchdog) rotate(now time.Time) {
+	if elapsed := now.Sub(w.windowStart); elapsed >= w.config.Window {
+		if elapsed >= 2*w.config.Window {
+			w.previous = nil
+		} else {
+			w.previous = w.current
+		}
+		w.current = make(map[routeKey]*RouteLatency)
+		w.windowStart = now
+	}
+}
+
+// snapshot 输出仍在运行的慢请求所在goroutine的栈，SnapshotInterval内已输出过栈快照时只输出警告
+func (w *Watchdog) snapshot(method, path, route string, goid uint64, start time.Time) {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "[WARNING] slow request %s %s (route %q) still running after %v\n", method, path, route, time.Since(start))
+	if w.allowSnapshot(time.Now()) {
+		stack := goroutineStack(goid)
+		if stack == nil {
+			return
+		}
+		buf.Write(stack)
+		buf.WriteByte('\n')
+	}
+
+	w.output.Lock()
+	w.config.Output.Write(buf.Bytes())
+	w.output.Unlock()
+}
+
+// allowSnapshot 距上次获取栈快照已超过SnapshotInterval时返回true并记录本次时间
+func (w *Watchdog) allowSnapshot(now time.Time) bool {
+	last := atomic.LoadInt64(&w.lastSnapshot)
+	if last != 0 && now.Sub(time.Unix(0, last)) < w.config.SnapshotInterval {
+		return false
+	}
+	return atomic.CompareAndSwapInt64(&w.lastSnapshot, last, now.UnixNano())
+}
+
+// currentGoroutineID 返回当前goroutine的id
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// goroutine 123 [running]:
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		id, _ := strconv.ParseUint(string(buf[:i]), 10, 64)
+		return id
+	}
+	return 0
+}
+
+// goroutineStack 返回指定goroutine的栈，goroutine已结束时返回nil
+func goroutineStack(goid uint64) []byte {
+	buf := make([]byte, watchdogStackBufSize)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= watchdogStackMaxSize {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+
+	header := []byte("goroutine " + strconv.FormatUint(goid, 10) + " [")
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, header) {
+			return stack
+		}
+	}
+	return nil
+}
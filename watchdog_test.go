@@ -0,0 +1,127 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 可并发写入的bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchdogSlowRequest(t *testing.T) {
+	output := &syncBuffer{}
+	watchdog := NewWatchdog(WatchdogConfig{Threshold: 20 * time.Millisecond, Output: output, SnapshotInterval: time.Hour})
+	s := New()
+	var mu sync.Mutex
+	slow := map[string]bool{}
+	s.Trace(func(c *Context) {
+		mu.Lock()
+		slow[c.Request.URL.Path] = c.Trace.Slow
+		mu.Unlock()
+	})
+	s.Use(watchdog.Middleware())
+	s.GET("/fast", func(c *Context) {})
+	s.GET("/slow/:id", func(c *Context) { time.Sleep(40 * time.Millisecond) })
+	s.buildTrees()
+
+	serve(s, http.MethodGet, "/fast")
+	var wg sync.WaitGroup
+	for _, path := range []string{"/slow/1", "/slow/2"} {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			serve(s, http.MethodGet, path)
+		}(path)
+	}
+	wg.Wait()
+
+	if len(slow) != 3 || slow["/fast"] || !slow["/slow/1"] || !slow["/slow/2"] {
+		t.Errorf("Trace.Slow = %v, want only /slow/* slow", slow)
+	}
+	// 两个慢请求都输出警告，SnapshotInterval内只输出一次goroutine栈
+	out := output.String()
+	if n := strings.Count(out, `slow request GET /slow/`); n != 2 {
+		t.Errorf("output has %d warnings, want 2:\n%s", n, out)
+	}
+	if n := strings.Count(out, "\ngoroutine "); n != 1 || !strings.Contains(out, "time.Sleep") {
+		t.Errorf("output has %d goroutine stacks, want 1 with time.Sleep:\n%s", n, out)
+	}
+
+	routes := watchdog.Slowest()
+	if len(routes) != 2 || routes[0].Route != "/slow/:id" || routes[0].Count != 2 || routes[0].Slow != 2 || routes[1].Route != "/fast" {
+		t.Errorf("Slowest = %+v", routes)
+	}
+}
+
+func TestWatchdogAllowSnapshot(t *testing.T) {
+	watchdog := NewWatchdog(WatchdogConfig{SnapshotInterval: time.Minute})
+	now := time.Now()
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{now, true},
+		{now.Add(time.Second), false},
+		{now.Add(59 * time.Second), false},
+		{now.Add(time.Minute), true},
+		{now.Add(time.Minute + time.Second), false},
+	}
+	for _, tt := range tests {
+		if got := watchdog.allowSnapshot(tt.at); got != tt.want {
+			t.Errorf("allowSnapshot(+%v) = %v, want %v", tt.at.Sub(now), got, tt.want)
+		}
+	}
+}
+
+func TestWatchdogReport(t *testing.T) {
+	watchdog := NewWatchdog(WatchdogConfig{Threshold: time.Second, TopN: 2, Window: time.Hour})
+	for i, route := range []string{"/a", "/b", "/c"} {
+		watchdog.observe(http.MethodGet, route, route, time.Duration(i+1)*time.Second, true)
+	}
+	watchdog.observe(http.MethodGet, "/a", "/a?x", 100*time.Millisecond, false)
+
+	s := New()
+	s.GET("/slow-routes", watchdog.Report())
+	s.buildTrees()
+	w := do(s, httptest.NewRequest(http.MethodGet, "/slow-routes", nil))
+
+	var result struct {
+		Data []RouteLatency `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Report = %s: %v", w.Body.String(), err)
+	}
+	if len(result.Data) != 2 || result.Data[0].Route != "/c" || result.Data[1].Route != "/b" {
+		t.Errorf("Report = %+v, want /c and /b", result.Data)
+	}
+
+	watchdog.config.TopN = 3
+	if a := watchdog.Slowest()[2]; a.Route != "/a" || a.Count != 2 || a.Slow != 1 || a.Max != time.Second || a.MaxPath != "/a" || a.Average != 550*time.Millisecond {
+		t.Errorf("Slowest /a = %+v", a)
+	}
+}
@@ -0,0 +1,781 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// websocket message types (RFC 6455 opcode)
+const (
+	TextMessage   int = 1
+	BinaryMessage int = 2
+	CloseMessage  int = 8
+	PingMessage   int = 9
+	PongMessage   int = 10
+
+	continuationFrame int = 0
+)
+
+// websocket close codes (RFC 6455 7.4.1)
+const (
+	CloseNormalClosure           int = 1000
+	CloseGoingAway               int = 1001
+	CloseProtocolError           int = 1002
+	CloseUnsupportedData         int = 1003
+	CloseNoStatusReceived        int = 1005
+	CloseAbnormalClosure         int = 1006
+	CloseInvalidFramePayloadData int = 1007
+	ClosePolicyViolation         int = 1008
+	CloseMessageTooBig           int = 1009
+	CloseMandatoryExtension      int = 1010
+	CloseInternalServerErr       int = 1011
+)
+
+const (
+	// websocket握手GUID
+	websocketGUID string = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// 默认的单条消息最大长度
+	defaultWebSocketReadLimit int64 = 16 << 20 // 16 MB
+	// 控制帧最大payload长度
+	maxControlFramePayload int = 125
+	// close帧写超时
+	websocketCloseTimeout = 5 * time.Second
+)
+
+var (
+	// ErrBadHandshake websocket握手失败
+	ErrBadHandshake = errors.New("nets: websocket bad handshake")
+	// ErrWebSocketClosed websocket连接已关闭
+	ErrWebSocketClosed = errors.New("nets: websocket connection closed")
+
+	// deflate压缩数据块的尾部
+	deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+)
+
+// WebSocketConfig websocket配置
+type WebSocketConfig struct {
+	// 服务端支持的子协议，按优先级排序
+	Subprotocols []string
+	// 校验Origin请求头，为nil时要求Origin与Host一致
+	CheckOrigin func(r *http.Request) bool
+	// 是否启用permessage-deflate压缩
+	EnableCompression bool
+	// 单条消息最大长度，小于等于0时使用默认值
+	ReadLimit int64
+	// 写消息时单帧最大payload长度，小于等于0时不分片
+	FragmentSize int
+	// 客户端握手超时，小于等于0时不超时
+	HandshakeTimeout time.Duration
+}
+
+// CloseError websocket close帧携带的关闭信息
+type CloseError struct {
+	Code int
+	Text string
+}
+
+// Error 实现error接口
+func (e *CloseError) Error() string {
+	return "nets: websocket closed: " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// WebSocketConn websocket连接
+// ReadMessage与WriteMessage分别只允许一个goroutine调用，写方法之间可以并发调用
+type WebSocketConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	isServer    bool
+	compress    bool
+	subprotocol string
+	readLimit   int64
+	fragment    int
+	codec       JSONCodec
+	writeMu     sync.Mutex
+	closeSent   bool
+
+	// ping帧处理函数，默认回复pong帧
+	PingHandler func(data []byte) error
+	// pong帧处理函数，默认忽略
+	PongHandler func(data []byte) error
+}
+
+// Upgrade 将当前请求升级为websocket连接
+// 握手失败时响应对应的http status并返回错误
+func (c *Context) Upgrade(config ...WebSocketConfig) (*WebSocketConn, error) {
+	c.guard()
+	cfg := WebSocketConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	r := c.Request
+	status, err := checkWebSocketHandshake(r, cfg)
+	if err != nil {
+		c.AbortStatus(status)
+		c.Error(err)
+		return nil, err
+	}
+
+	subprotocol := selectSubprotocol(r, cfg.Subprotocols)
+	compress := cfg.EnableCompression && acceptsDeflate(r.Header)
+
+	conn, brw, err := c.responser.Hijack()
+	if err != nil {
+		c.Error(err)
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + websocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+	if subprotocol != "" {
+		buf.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		buf.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	buf.WriteString("\r\n")
+
+	if _, err = conn.Write(buf.Bytes()); err != nil {
+		conn.Close()
+		c.Error(err)
+		return nil, err
+	}
+
+	if c.server.Config.trace {
+		c.Trace.Status = http.StatusSwitchingProtocols
+	}
+
+	ws := newWebSocketConn(conn, brw.Reader, true, compress, subprotocol, cfg)
+	ws.codec = c.server.json
+	return ws, nil
+}
+
+// DialWebSocket 建立websocket客户端连接，rawurl的scheme为ws或wss
+func DialWebSocket(rawurl string, header http.Header, config ...WebSocketConfig) (*WebSocketConn, *http.Response, error) {
+	cfg := WebSocketConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host := u.Host
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		u.Scheme = "https"
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported scheme %q", ErrBadHandshake, u.Scheme)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.HandshakeTimeout}
+	var conn net.Conn
+	if u.Scheme == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.HandshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(cfg.HandshakeTimeout))
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err = rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req := &http.Request{Method: http.MethodGet, URL: u, Header: make(http.Header), Host: u.Host}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(cfg.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(cfg.Subprotocols, ", "))
+	}
+	if cfg.EnableCompression {
+		req.Header.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+	}
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContainsToken(resp.Header, "Upgrade", "websocket") ||
+		!headerContainsToken(resp.Header, "Connection", "upgrade") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		conn.Close()
+		return nil, resp, ErrBadHandshake
+	}
+
+	conn.SetDeadline(time.Time{})
+	compress := cfg.EnableCompression && acceptsDeflate(resp.Header)
+	subprotocol := resp.Header.Get("Sec-WebSocket-Protocol")
+
+	return newWebSocketConn(conn, reader, false, compress, subprotocol, cfg), resp, nil
+}
+
+// newWebSocketConn return new *WebSocketConn
+func newWebSocketConn(conn net.Conn, reader *bufio.Reader, isServer, compress bool, subprotocol string, cfg WebSocketConfig) *WebSocketConn {
+	ws := &WebSocketConn{
+		conn:        conn,
+		reader:      reader,
+		isServer:    isServer,
+		compress:    compress,
+		subprotocol: subprotocol,
+		readLimit:   cfg.ReadLimit,
+		fragment:    cfg.FragmentSize,
+		codec:       newJSONCodec(),
+	}
+	if ws.readLimit <= 0 {
+		ws.readLimit = defaultWebSocketReadLimit
+	}
+	ws.PingHandler = func(data []byte) error {
+		return ws.WriteControl(PongMessage, data)
+	}
+	return ws
+}
+
+// Subprotocol 返回协商的子协议
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// Compressed 是否已协商permessage-deflate压缩
+func (ws *WebSocketConn) Compressed() bool {
+	return ws.compress
+}
+
+// NetConn 返回底层连接
+func (ws *WebSocketConn) NetConn() net.Conn {
+	return ws.conn
+}
+
+// SetReadDeadline 设置读超时
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline 设置写超时
+func (ws *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// ReadJSON 读取一条消息并反序列化到v
+func (ws *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return ws.codec.Unmarshal(data, v)
+}
+
+// ReadMessage 读取一条完整的数据消息，自动合并分片并处理控制帧
+// 收到close帧时回复close帧并返回*CloseError
+func (ws *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	var compressed bool
+	buf := new(bytes.Buffer)
+	for {
+		fin, rsv1, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if ws.PingHandler != nil {
+				if err = ws.PingHandler(payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case PongMessage:
+			if ws.PongHandler != nil {
+				if err = ws.PongHandler(payload); err != nil {
+					return 0, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, ws.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected data frame during fragmented message")
+			}
+			messageType, compressed = opcode, rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			if rsv1 {
+				return 0, nil, ws.fail(CloseProtocolError, "rsv1 set on continuation frame")
+			}
+		}
+
+		if int64(buf.Len()+len(payload)) > ws.readLimit {
+			return 0, nil, ws.fail(CloseMessageTooBig, "message too big")
+		}
+		buf.Write(payload)
+
+		if fin {
+			break
+		}
+	}
+
+	data = buf.Bytes()
+	if compressed {
+		if data, err = inflateMessage(data, ws.readLimit); err != nil {
+			return 0, nil, ws.fail(CloseInvalidFramePayloadData, "invalid compressed data")
+		}
+	}
+
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return 0, nil, ws.fail(CloseInvalidFramePayloadData, "invalid utf8 payload")
+	}
+
+	return messageType, data, nil
+}
+
+// WriteMessage 发送一条数据消息，messageType为TextMessage或BinaryMessage
+// 配置了FragmentSize时按大小分片发送
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("nets: websocket invalid data message type %d", messageType)
+	}
+
+	compressed := false
+	if ws.compress {
+		deflated, err := deflateMessage(data)
+		if err != nil {
+			return err
+		}
+		data, compressed = deflated, true
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+
+	opcode := messageType
+	for {
+		payload, fin := data, true
+		if ws.fragment > 0 && len(data) > ws.fragment {
+			payload, fin = data[:ws.fragment], false
+		}
+		if err := ws.writeFrame(fin, compressed && opcode != continuationFrame, opcode, payload); err != nil {
+			return err
+		}
+		if fin {
+			return nil
+		}
+		data, opcode = data[len(payload):], continuationFrame
+	}
+}
+
+// WriteJSON 序列化v并以文本消息发送，服务端连接使用Server的JSONCodec
+func (ws *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := ws.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, data)
+}
+
+// WriteControl 发送控制帧，messageType为PingMessage、PongMessage或CloseMessage
+func (ws *WebSocketConn) WriteControl(messageType int, data []byte) error {
+	if messageType != PingMessage && messageType != PongMessage && messageType != CloseMessage {
+		return fmt.Errorf("nets: websocket invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlFramePayload {
+		return fmt.Errorf("nets: websocket control frame payload too big")
+	}
+
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+	if messageType == CloseMessage {
+		ws.closeSent = true
+	}
+	return ws.writeFrame(true, false, messageType, data)
+}
+
+// Ping 发送ping帧
+func (ws *WebSocketConn) Ping(data []byte) error {
+	return ws.WriteControl(PingMessage, data)
+}
+
+// WriteClose 发送close帧，之后不能再发送任何消息
+func (ws *WebSocketConn) WriteClose(code int, text string) error {
+	ws.conn.SetWriteDeadline(time.Now().Add(websocketCloseTimeout))
+	return ws.WriteControl(CloseMessage, closePayload(code, text))
+}
+
+// Close 关闭底层连接，不发送close帧
+func (ws *WebSocketConn) Close() error {
+	return ws.conn.Close()
+}
+
+// handleClose 处理收到的close帧：校验关闭码、回复close帧
+func (ws *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		return ws.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return ws.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.Valid(payload[2:]) {
+			return ws.fail(CloseInvalidFramePayloadData, "invalid utf8 close reason")
+		}
+	}
+
+	echo := []byte{}
+	if closeErr.Code != CloseNoStatusReceived {
+		echo = closePayload(closeErr.Code, "")
+	}
+	if err := ws.WriteControl(CloseMessage, echo); err != nil && err != ErrWebSocketClosed {
+		return err
+	}
+	return closeErr
+}
+
+// fail 发送带关闭码的close帧并返回对应的*CloseError
+func (ws *WebSocketConn) fail(code int, text string) error {
+	ws.WriteClose(code, text)
+	return &CloseError{Code: code, Text: text}
+}
+
+// readFrame 读取一帧
+func (ws *WebSocketConn) readFrame() (fin, rsv1 bool, opcode int, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(ws.reader, header); err != nil {
+		return
+	}
+
+	fin, rsv1 = header[0]&0x80 != 0, header[0]&0x40 != 0
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	switch {
+	case header[0]&0x30 != 0:
+		err = ws.fail(CloseProtocolError, "unexpected rsv2 or rsv3")
+		return
+	case rsv1 && !ws.compress:
+		err = ws.fail(CloseProtocolError, "unexpected rsv1")
+		return
+	case masked != ws.isServer:
+		err = ws.fail(CloseProtocolError, "invalid frame mask")
+		return
+	}
+
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > uint64(maxControlFramePayload) || rsv1 {
+			err = ws.fail(CloseProtocolError, "invalid control frame")
+			return
+		}
+	default:
+		err = ws.fail(CloseProtocolError, "unknown opcode "+strconv.Itoa(opcode))
+		return
+	}
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(ws.reader, ext); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(ws.reader, ext); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if length > uint64(ws.readLimit) {
+		err = ws.fail(CloseMessageTooBig, "message too big")
+		return
+	}
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(ws.reader, mask); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+
+	return
+}
+
+// writeFrame 写入一帧，调用方需持有writeMu
+func (ws *WebSocketConn) writeFrame(fin, rsv1 bool, opcode int, payload []byte) error {
+	buf := new(bytes.Buffer)
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	buf.WriteByte(b0)
+
+	var b1 byte
+	if !ws.isServer {
+		b1 = 0x80
+	}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		buf.WriteByte(b1 | byte(length))
+	case length <= 0xffff:
+		buf.WriteByte(b1 | 126)
+		binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(b1 | 127)
+		binary.Write(buf, binary.BigEndian, uint64(length))
+	}
+
+	if ws.isServer {
+		buf.Write(payload)
+	} else {
+		mask := make([]byte, 4)
+		if _, err := rand.Read(mask); err != nil {
+			return err
+		}
+		buf.Write(mask)
+		start := buf.Len()
+		buf.Write(payload)
+		maskBytes(mask, buf.Bytes()[start:])
+	}
+
+	_, err := ws.conn.Write(buf.Bytes())
+	return err
+}
+
+// maskBytes 使用mask对data做异或掩码
+func maskBytes(mask, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// deflateMessage permessage-deflate压缩，去掉尾部的0x00 0x00 0xff 0xff
+func deflateMessage(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := flate.NewWriter(buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// inflateMessage permessage-deflate解压，解压后长度不超过limit
+func inflateMessage(data []byte, limit int64) ([]byte, error) {
+	// 补回尾部并追加一个空的final stored block
+	tail := append(append([]byte{}, deflateTail...), 0x01, 0x00, 0x00, 0xff, 0xff)
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(tail)))
+	defer r.Close()
+
+	inflated, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(inflated)) > limit {
+		return nil, errors.New("nets: websocket inflated message too big")
+	}
+	return inflated, nil
+}
+
+// closePayload 构造close帧payload
+func closePayload(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	if len(text) > maxControlFramePayload-2 {
+		text = text[:maxControlFramePayload-2]
+	}
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, text...)
+}
+
+// validCloseCode 是否为可在close帧中发送的关闭码
+func validCloseCode(code int) bool {
+	switch code {
+	case CloseNormalClosure, CloseGoingAway, CloseProtocolError, CloseUnsupportedData,
+		CloseInvalidFramePayloadData, ClosePolicyViolation, CloseMessageTooBig,
+		CloseMandatoryExtension, CloseInternalServerErr:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// checkWebSocketHandshake 校验websocket握手请求，失败时返回对应的http status
+func checkWebSocketHandshake(r *http.Request, cfg WebSocketConfig) (int, error) {
+	if r.Method != http.MethodGet {
+		return http.StatusMethodNotAllowed, fmt.Errorf("%w: method is not GET", ErrBadHandshake)
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return http.StatusBadRequest, fmt.Errorf("%w: 'upgrade' token not found in 'Connection' header", ErrBadHandshake)
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return http.StatusBadRequest, fmt.Errorf("%w: 'websocket' token not found in 'Upgrade' header", ErrBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return http.StatusUpgradeRequired, fmt.Errorf("%w: unsupported version", ErrBadHandshake)
+	}
+	if key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key")); err != nil || len(key) != 16 {
+		return http.StatusBadRequest, fmt.Errorf("%w: invalid 'Sec-WebSocket-Key' header", ErrBadHandshake)
+	}
+
+	checkOrigin := cfg.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return http.StatusForbidden, fmt.Errorf("%w: origin not allowed", ErrBadHandshake)
+	}
+
+	return http.StatusSwitchingProtocols, nil
+}
+
+// sameOrigin Origin请求头为空或与Host一致
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// selectSubprotocol 按服务端优先级选择客户端支持的子协议
+func selectSubprotocol(r *http.Request, subprotocols []string) string {
+	offered := headerTokens(r.Header, "Sec-WebSocket-Protocol")
+	for _, v := range subprotocols {
+		for _, o := range offered {
+			if v == o {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// acceptsDeflate 扩展请求头中是否包含permessage-deflate
+func acceptsDeflate(header http.Header) bool {
+	for _, ext := range headerTokens(header, "Sec-WebSocket-Extensions") {
+		if strings.EqualFold(strings.TrimSpace(strings.Split(ext, ";")[0]), "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// websocketAccept 计算Sec-WebSocket-Accept
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerTokens 返回逗号分隔的请求头值列表
+func headerTokens(header http.Header, name string) (tokens []string) {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				tokens = append(tokens, v)
+			}
+		}
+	}
+	return
+}
+
+// headerContainsToken 请求头中是否包含token（忽略大小写）
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, v := range headerTokens(header, name) {
+		if strings.EqualFold(v, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+// Copyright 2020 songdengtao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package nets
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newEchoServer 启动回显websocket消息的测试服务，服务端ReadMessage返回的错误写入errs
+func newEchoServer(config WebSocketConfig) (server *httptest.Server, wsURL string, errs chan error) {
+	errs = make(chan error, 1)
+	s := New()
+	s.GET("/echo", func(c *Context) {
+		ws, err := c.Upgrade(config)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer ws.Close()
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err = ws.WriteMessage(messageType, data); err != nil {
+				errs <- err
+				return
+			}
+		}
+	})
+	s.buildTrees()
+	server = httptest.NewServer(s)
+	return server, "ws" + strings.TrimPrefix(server.URL, "http") + "/echo", errs
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	long := []byte(strings.Repeat("nets websocket ", 100))
+	tests := []struct {
+		name       string
+		server     WebSocketConfig
+		client     WebSocketConfig
+		typ        int
+		data       []byte
+		compressed bool
+	}{
+		{"text", WebSocketConfig{}, WebSocketConfig{}, TextMessage, []byte("hello"), false},
+		{"binary", WebSocketConfig{}, WebSocketConfig{}, BinaryMessage, []byte{0x00, 0xff, 0x80}, false},
+		{"empty", WebSocketConfig{}, WebSocketConfig{}, TextMessage, []byte{}, false},
+		{"extended length", WebSocketConfig{}, WebSocketConfig{}, BinaryMessage, bytes.Repeat([]byte{1}, 70000), false},
+		{"fragmented", WebSocketConfig{FragmentSize: 3}, WebSocketConfig{FragmentSize: 7}, TextMessage, long, false},
+		{"deflate", WebSocketConfig{EnableCompression: true}, WebSocketConfig{EnableCompression: true}, TextMessage, long, true},
+		{"deflate fragmented", WebSocketConfig{EnableCompression: true, FragmentSize: 5}, WebSocketConfig{EnableCompression: true, FragmentSize: 5}, BinaryMessage, long, true},
+		{"deflate server only", WebSocketConfig{EnableCompression: true}, WebSocketConfig{}, TextMessage, long, false},
+		{"deflate client only", WebSocketConfig{}, WebSocketConfig{EnableCompression: true}, TextMessage, long, false},
+	}
+	for _, tt := range tests {
+		server, wsURL, _ := newEchoServer(tt.server)
+		ws, _, err := DialWebSocket(wsURL, nil, tt.client)
+		if err != nil {
+			t.Fatalf("%s: DialWebSocket = %v", tt.name, err)
+		}
+		if ws.Compressed() != tt.compressed {
+			t.Errorf("%s: Compressed = %v, want %v", tt.name, ws.Compressed(), tt.compressed)
+		}
+		if err = ws.WriteMessage(tt.typ, tt.data); err != nil {
+			t.Fatalf("%s: WriteMessage = %v", tt.name, err)
+		}
+		typ, data, err := ws.ReadMessage()
+		if err != nil || typ != tt.typ || !bytes.Equal(data, tt.data) {
+			t.Errorf("%s: ReadMessage = %d, %d bytes, %v, want %d, %d bytes", tt.name, typ, len(data), err, tt.typ, len(tt.data))
+		}
+		ws.Close()
+		server.Close()
+	}
+}
+
+func TestWebSocketPingPong(t *testing.T) {
+	server, wsURL, _ := newEchoServer(WebSocketConfig{})
+	defer server.Close()
+	ws, _, err := DialWebSocket(wsURL, nil)
+	if err != nil {
+		t.Fatalf("DialWebSocket = %v", err)
+	}
+	defer ws.Close()
+
+	var pongs []string
+	ws.PongHandler = func(data []byte) error {
+		pongs = append(pongs, string(data))
+		return nil
+	}
+	ws.Ping([]byte("1"))
+	ws.Ping([]byte("2"))
+	ws.WriteMessage(TextMessage, []byte("hello"))
+
+	// 服务端按顺序回复pong，ReadMessage处理控制帧后返回数据消息
+	if _, data, err := ws.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("ReadMessage = %q, %v", data, err)
+	}
+	if strings.Join(pongs, ",") != "1,2" {
+		t.Errorf("pongs = %v, want [1 2]", pongs)
+	}
+
+	if err = ws.Ping(bytes.Repeat([]byte{1}, maxControlFramePayload+1)); err == nil {
+		t.Error("Ping with oversized payload succeeded")
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	tests := []struct {
+		code       int
+		text       string
+		serverCode int // 服务端ReadMessage返回的关闭码
+		clientCode int // 客户端收到的关闭码
+	}{
+		{CloseNormalClosure, "bye", CloseNormalClosure, CloseNormalClosure},
+		{CloseGoingAway, "", CloseGoingAway, CloseGoingAway},
+		{4000, "app", 4000, 4000},
+		{CloseNoStatusReceived, "", CloseNoStatusReceived, CloseNoStatusReceived},
+		{1004, "reserved", CloseProtocolError, CloseProtocolError},
+		{2999, "", CloseProtocolError, CloseProtocolError},
+	}
+	for _, tt := range tests {
+		server, wsURL, errs := newEchoServer(WebSocketConfig{})
+		ws, _, err := DialWebSocket(wsURL, nil)
+		if err != nil {
+			t.Fatalf("DialWebSocket = %v", err)
+		}
+
+		if err = ws.WriteClose(tt.code, tt.text); err != nil {
+			t.Fatalf("WriteClose(%d) = %v", tt.code, err)
+		}
+		if err = ws.WriteMessage(TextMessage, []byte("late")); err != ErrWebSocketClosed {
+			t.Errorf("WriteMessage after close = %v, want ErrWebSocketClosed", err)
+		}
+
+		var serverErr *CloseError
+		if err = <-errs; !errors.As(err, &serverErr) || serverErr.Code != tt.serverCode {
+			t.Errorf("close %d: server got %v, want code %d", tt.code, err, tt.serverCode)
+		} else if tt.serverCode == tt.code && serverErr.Text != tt.text {
+			t.Errorf("close %d: server got text %q, want %q", tt.code, serverErr.Text, tt.text)
+		}
+		var clientErr *CloseError
+		if _, _, err = ws.ReadMessage(); !errors.As(err, &clientErr) || clientErr.Code != tt.clientCode {
+			t.Errorf("close %d: client got %v, want code %d", tt.code, err, tt.clientCode)
+		}
+		ws.Close()
+		server.Close()
+	}
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+	}{
+		{"method", http.MethodPost, nil, http.StatusMethodNotAllowed},
+		{"connection", http.MethodGet, map[string]string{"Connection": "keep-alive"}, http.StatusBadRequest},
+		{"upgrade", http.MethodGet, map[string]string{"Upgrade": "h2c"}, http.StatusBadRequest},
+		{"version", http.MethodGet, map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"key", http.MethodGet, map[string]string{"Sec-WebSocket-Key": "bmV0cw=="}, http.StatusBadRequest},
+		{"origin", http.MethodGet, map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		var upgradeErr error
+		s := New()
+		upgrade := func(c *Context) {
+			_, upgradeErr = c.Upgrade()
+		}
+		s.GET("/ws", upgrade)
+		s.POST("/ws", upgrade)
+		s.buildTrees()
+
+		req := httptest.NewRequest(tt.method, "/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		w := do(s, req)
+		if w.Code != tt.status || !errors.Is(upgradeErr, ErrBadHandshake) {
+			t.Errorf("%s: status %d, err %v, want %d, ErrBadHandshake", tt.name, w.Code, upgradeErr, tt.status)
+		}
+	}
+}
+
+func TestDialWebSocketBadHandshake(t *testing.T) {
+	s := New()
+	s.GET("/plain", func(c *Context) { c.JSON(http.StatusOK, Result{}) })
+	s.buildTrees()
+	server := httptest.NewServer(s)
+	defer server.Close()
+	base := strings.TrimPrefix(server.URL, "http")
+
+	tests := []struct {
+		url    string
+		status int // 0 表示没有响应
+	}{
+		{"ws" + base + "/plain", http.StatusOK},
+		{"ws" + base + "/none", http.StatusNotFound},
+		{"http" + base + "/plain", 0},
+	}
+	for _, tt := range tests {
+		ws, resp, err := DialWebSocket(tt.url, nil)
+		if ws != nil {
+			ws.Close()
+		}
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		if !errors.Is(err, ErrBadHandshake) || status != tt.status {
+			t.Errorf("DialWebSocket(%s) = %v, status %d, want ErrBadHandshake, status %d", tt.url, err, status, tt.status)
+		}
+	}
+}